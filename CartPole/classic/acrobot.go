@@ -0,0 +1,107 @@
+package classic
+
+import "math"
+
+const (
+	acrobotDt       = 0.2
+	acrobotLinkLen1 = 1.0
+	acrobotMass1    = 1.0
+	acrobotMass2    = 1.0
+	acrobotCOM1     = 0.5
+	acrobotCOM2     = 0.5
+	acrobotMOI      = 1.0
+	acrobotMaxVel1  = 4 * math.Pi
+	acrobotMaxVel2  = 9 * math.Pi
+	acrobotGravity  = 9.8
+)
+
+var acrobotTorques = []float64{-1, 0, 1}
+
+// acrobot implements Acrobot-v1, using the equations of
+// motion from the book (not the NIPS paper), like gym.
+//
+// The state is (theta1, theta2, dtheta1, dtheta2).
+type acrobot struct {
+	state [4]float64
+}
+
+func (a *acrobot) Reset(r *random) {
+	for i := range a.state {
+		a.state[i] = r.Uniform(-0.1, 0.1)
+	}
+}
+
+func (a *acrobot) Step(action []float64) (reward float64, done bool) {
+	torque := acrobotTorques[int(action[0])]
+	ns := rk4(a.state, torque, acrobotDt)
+	ns[0] = wrap(ns[0], -math.Pi, math.Pi)
+	ns[1] = wrap(ns[1], -math.Pi, math.Pi)
+	ns[2] = clip(ns[2], -acrobotMaxVel1, acrobotMaxVel1)
+	ns[3] = clip(ns[3], -acrobotMaxVel2, acrobotMaxVel2)
+	a.state = ns
+
+	if -math.Cos(ns[0])-math.Cos(ns[1]+ns[0]) > 1 {
+		return 0, true
+	}
+	return -1, false
+}
+
+func (a *acrobot) Observe() []float64 {
+	s := a.state
+	return []float64{
+		math.Cos(s[0]), math.Sin(s[0]),
+		math.Cos(s[1]), math.Sin(s[1]),
+		s[2], s[3],
+	}
+}
+
+// rk4 takes one fourth-order Runge-Kutta step.
+func rk4(s [4]float64, torque, dt float64) [4]float64 {
+	offset := func(s, d [4]float64, scale float64) [4]float64 {
+		for i := range s {
+			s[i] += d[i] * scale
+		}
+		return s
+	}
+	k1 := acrobotDerivs(s, torque)
+	k2 := acrobotDerivs(offset(s, k1, dt/2), torque)
+	k3 := acrobotDerivs(offset(s, k2, dt/2), torque)
+	k4 := acrobotDerivs(offset(s, k3, dt), torque)
+	var res [4]float64
+	for i := range res {
+		res[i] = s[i] + dt/6*(k1[i]+2*k2[i]+2*k3[i]+k4[i])
+	}
+	return res
+}
+
+func acrobotDerivs(s [4]float64, torque float64) [4]float64 {
+	m1, m2 := acrobotMass1, acrobotMass2
+	l1 := acrobotLinkLen1
+	lc1, lc2 := acrobotCOM1, acrobotCOM2
+	i1, i2 := acrobotMOI, acrobotMOI
+	g := acrobotGravity
+	theta1, theta2, dtheta1, dtheta2 := s[0], s[1], s[2], s[3]
+
+	d1 := m1*lc1*lc1 + m2*(l1*l1+lc2*lc2+2*l1*lc2*math.Cos(theta2)) + i1 + i2
+	d2 := m2*(lc2*lc2+l1*lc2*math.Cos(theta2)) + i2
+	phi2 := m2 * lc2 * g * math.Cos(theta1+theta2-math.Pi/2)
+	phi1 := -m2*l1*lc2*dtheta2*dtheta2*math.Sin(theta2) -
+		2*m2*l1*lc2*dtheta2*dtheta1*math.Sin(theta2) +
+		(m1*lc1+m2*l1)*g*math.Cos(theta1-math.Pi/2) + phi2
+	ddtheta2 := (torque + d2/d1*phi1 - m2*l1*lc2*dtheta1*dtheta1*math.Sin(theta2) - phi2) /
+		(m2*lc2*lc2 + i2 - d2*d2/d1)
+	ddtheta1 := -(d2*ddtheta2 + phi1) / d1
+	return [4]float64{dtheta1, dtheta2, ddtheta1, ddtheta2}
+}
+
+// wrap wraps x into [min, max].
+func wrap(x, min, max float64) float64 {
+	diff := max - min
+	for x > max {
+		x -= diff
+	}
+	for x < min {
+		x += diff
+	}
+	return x
+}
@@ -0,0 +1,57 @@
+package classic
+
+import "math"
+
+const (
+	cartPoleGravity    = 9.8
+	cartPoleMassCart   = 1.0
+	cartPoleMassPole   = 0.1
+	cartPoleTotalMass  = cartPoleMassCart + cartPoleMassPole
+	cartPoleLength     = 0.5
+	cartPolePoleMassL  = cartPoleMassPole * cartPoleLength
+	cartPoleForceMag   = 10.0
+	cartPoleTau        = 0.02
+	cartPoleThetaLimit = 12 * 2 * math.Pi / 360
+	cartPoleXLimit     = 2.4
+)
+
+// cartPole implements CartPole-v0 and CartPole-v1, which
+// only differ in their time limits.
+//
+// The state is (x, xDot, theta, thetaDot).
+type cartPole struct {
+	state [4]float64
+}
+
+func (c *cartPole) Reset(r *random) {
+	for i := range c.state {
+		c.state[i] = r.Uniform(-0.05, 0.05)
+	}
+}
+
+func (c *cartPole) Step(action []float64) (reward float64, done bool) {
+	x, xDot, theta, thetaDot := c.state[0], c.state[1], c.state[2], c.state[3]
+	force := -cartPoleForceMag
+	if action[0] == 1 {
+		force = cartPoleForceMag
+	}
+	cosTheta, sinTheta := math.Cos(theta), math.Sin(theta)
+	temp := (force + cartPolePoleMassL*thetaDot*thetaDot*sinTheta) / cartPoleTotalMass
+	thetaAcc := (cartPoleGravity*sinTheta - cosTheta*temp) /
+		(cartPoleLength * (4.0/3.0 - cartPoleMassPole*cosTheta*cosTheta/cartPoleTotalMass))
+	xAcc := temp - cartPolePoleMassL*thetaAcc*cosTheta/cartPoleTotalMass
+
+	x += cartPoleTau * xDot
+	xDot += cartPoleTau * xAcc
+	theta += cartPoleTau * thetaDot
+	thetaDot += cartPoleTau * thetaAcc
+	c.state = [4]float64{x, xDot, theta, thetaDot}
+
+	done = x < -cartPoleXLimit || x > cartPoleXLimit ||
+		theta < -cartPoleThetaLimit || theta > cartPoleThetaLimit
+	return 1, done
+}
+
+func (c *cartPole) Observe() []float64 {
+	return append([]float64{}, c.state[:]...)
+}
@@ -0,0 +1,228 @@
+// Package classic implements gym's classic control
+// environments in pure Go.
+//
+// The dynamics, rewards, and termination rules follow
+// gym's implementations, including the time limits that
+// gym adds when an environment is created with gym.make().
+package classic
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+)
+
+// Spec describes an environment's observations and
+// actions.
+type Spec struct {
+	Name    string
+	ObsSize int
+
+	// NumActions is the number of discrete actions.
+	// It is 0 for continuous environments.
+	NumActions int
+
+	// ActionSize is the size of continuous actions.
+	// ActionLow and ActionHigh bound each component.
+	ActionSize int
+	ActionLow  float64
+	ActionHigh float64
+
+	// MaxSteps is the episode time limit.
+	MaxSteps int
+}
+
+// Specs maps environment names to their specs.
+var Specs = map[string]*Spec{
+	"CartPole-v0": {
+		Name:       "CartPole-v0",
+		ObsSize:    4,
+		NumActions: 2,
+		MaxSteps:   200,
+	},
+	"CartPole-v1": {
+		Name:       "CartPole-v1",
+		ObsSize:    4,
+		NumActions: 2,
+		MaxSteps:   500,
+	},
+	"Acrobot-v1": {
+		Name:       "Acrobot-v1",
+		ObsSize:    6,
+		NumActions: 3,
+		MaxSteps:   500,
+	},
+	"MountainCar-v0": {
+		Name:       "MountainCar-v0",
+		ObsSize:    2,
+		NumActions: 3,
+		MaxSteps:   200,
+	},
+	"Pendulum-v0": {
+		Name:       "Pendulum-v0",
+		ObsSize:    3,
+		ActionSize: 1,
+		ActionLow:  -2,
+		ActionHigh: 2,
+		MaxSteps:   200,
+	},
+}
+
+// Discrete reports whether the environment has discrete
+// actions.
+func (s *Spec) Discrete() bool {
+	return s.NumActions > 0
+}
+
+// An Env is an anyrl.Env with a known spec.
+//
+// Discrete actions are given as vectors with one component
+// per action, and the largest component is taken (e.g. a
+// one-hot vector).
+// Continuous actions are clipped to the action bounds.
+type Env interface {
+	anyrl.Env
+	Spec() *Spec
+}
+
+// WithSpec attaches a spec to an anyrl.Env, such as a
+// wrapper around another Env.
+func WithSpec(env anyrl.Env, spec *Spec) Env {
+	return &specEnv{Env: env, spec: spec}
+}
+
+type specEnv struct {
+	anyrl.Env
+	spec *Spec
+}
+
+func (s *specEnv) Spec() *Spec {
+	return s.spec
+}
+
+// Make creates an environment by name.
+//
+// If gen is nil, the global random source is used.
+func Make(name string, c anyvec.Creator, gen *rand.Rand) (Env, error) {
+	spec, ok := Specs[name]
+	if !ok {
+		return nil, errors.New("make environment: unknown name: " + name)
+	}
+	var dyn dynamics
+	switch name {
+	case "CartPole-v0", "CartPole-v1":
+		dyn = &cartPole{}
+	case "Acrobot-v1":
+		dyn = &acrobot{}
+	case "MountainCar-v0":
+		dyn = &mountainCar{}
+	case "Pendulum-v0":
+		dyn = &pendulum{}
+	}
+	return &env{
+		creator:  c,
+		spec:     spec,
+		rand:     newRandom(gen),
+		dynamics: dyn,
+	}, nil
+}
+
+// dynamics implements an environment on float64 slices.
+type dynamics interface {
+	Reset(r *random)
+	Step(action []float64) (reward float64, done bool)
+	Observe() []float64
+}
+
+type env struct {
+	creator  anyvec.Creator
+	spec     *Spec
+	rand     *random
+	dynamics dynamics
+	timestep int
+}
+
+func (e *env) Spec() *Spec {
+	return e.spec
+}
+
+func (e *env) Reset() (anyvec.Vector, error) {
+	e.timestep = 0
+	e.dynamics.Reset(e.rand)
+	return e.observation(), nil
+}
+
+func (e *env) Step(action anyvec.Vector) (obs anyvec.Vector, reward float64,
+	done bool, err error) {
+	var act []float64
+	if e.spec.Discrete() {
+		if action.Len() != e.spec.NumActions {
+			return nil, 0, false, errors.New("step: bad action size")
+		}
+		act = []float64{float64(anyvec.MaxIndex(action))}
+	} else {
+		if action.Len() != e.spec.ActionSize {
+			return nil, 0, false, errors.New("step: bad action size")
+		}
+		act = vecFloats(action)
+		for i, x := range act {
+			act[i] = clip(x, e.spec.ActionLow, e.spec.ActionHigh)
+		}
+	}
+	reward, done = e.dynamics.Step(act)
+	e.timestep++
+	if e.timestep >= e.spec.MaxSteps {
+		done = true
+	}
+	return e.observation(), reward, done, nil
+}
+
+func (e *env) observation() anyvec.Vector {
+	return e.creator.MakeVectorData(e.creator.MakeNumericList(e.dynamics.Observe()))
+}
+
+// random wraps an optional *rand.Rand.
+type random struct {
+	gen *rand.Rand
+}
+
+func newRandom(gen *rand.Rand) *random {
+	return &random{gen: gen}
+}
+
+// Uniform samples uniformly from [low, high).
+func (r *random) Uniform(low, high float64) float64 {
+	var f float64
+	if r.gen == nil {
+		f = rand.Float64()
+	} else {
+		f = r.gen.Float64()
+	}
+	return low + f*(high-low)
+}
+
+func vecFloats(v anyvec.Vector) []float64 {
+	switch data := v.Data().(type) {
+	case []float64:
+		return data
+	case []float32:
+		res := make([]float64, len(data))
+		for i, x := range data {
+			res[i] = float64(x)
+		}
+		return res
+	default:
+		panic("unsupported numeric type")
+	}
+}
+
+func clip(x, min, max float64) float64 {
+	if x < min {
+		return min
+	} else if x > max {
+		return max
+	}
+	return x
+}
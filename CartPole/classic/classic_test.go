@@ -0,0 +1,82 @@
+package classic
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCartPoleStep(t *testing.T) {
+	c := &cartPole{}
+	reward, done := c.Step([]float64{1})
+	if reward != 1 || done {
+		t.Fatalf("unexpected reward %v and done %v", reward, done)
+	}
+	expected := []float64{0, 0.1951219512195122, 0, -0.2926829268292683}
+	assertClose(t, c.Observe(), expected)
+
+	// Always pushing right eventually drops the pole.
+	for i := 0; i < 100 && !done; i++ {
+		_, done = c.Step([]float64{1})
+	}
+	if !done {
+		t.Error("episode did not end")
+	}
+}
+
+func TestMountainCarStep(t *testing.T) {
+	m := &mountainCar{position: -0.5}
+	reward, done := m.Step([]float64{2})
+	if reward != -1 || done {
+		t.Fatalf("unexpected reward %v and done %v", reward, done)
+	}
+	velocity := 0.001 - math.Cos(-1.5)*0.0025
+	assertClose(t, m.Observe(), []float64{-0.5 + velocity, velocity})
+
+	// The car stops at the left wall.
+	m = &mountainCar{position: mountainCarMinPos, velocity: -0.01}
+	m.Step([]float64{0})
+	assertClose(t, m.Observe(), []float64{mountainCarMinPos, 0})
+}
+
+func TestAcrobotRest(t *testing.T) {
+	a := &acrobot{}
+	for i := 0; i < 10; i++ {
+		if reward, done := a.Step([]float64{1}); reward != -1 || done {
+			t.Fatalf("unexpected reward %v and done %v", reward, done)
+		}
+	}
+	assertClose(t, a.Observe(), []float64{1, 0, 1, 0, 0, 0})
+}
+
+func TestAcrobotEnergy(t *testing.T) {
+	// Without torque, a small swing should not blow up.
+	a := &acrobot{}
+	a.Reset(newRandom(rand.New(rand.NewSource(1))))
+	for i := 0; i < 500; i++ {
+		a.Step([]float64{1})
+	}
+	for _, x := range a.state[2:] {
+		if math.Abs(x) > 1 {
+			t.Fatalf("unexpected velocity: %v", a.state)
+		}
+	}
+}
+
+func TestAngleNormalize(t *testing.T) {
+	inputs := []float64{0, math.Pi / 2, 3 * math.Pi / 2, -3 * math.Pi / 2, 5 * math.Pi}
+	expected := []float64{0, math.Pi / 2, -math.Pi / 2, math.Pi / 2, -math.Pi}
+	for i, x := range inputs {
+		if actual := angleNormalize(x); math.Abs(actual-expected[i]) > 1e-8 {
+			t.Errorf("angleNormalize(%v): expected %v but got %v", x, expected[i], actual)
+		}
+	}
+}
+
+func assertClose(t *testing.T, actual, expected []float64) {
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-8 {
+			t.Fatalf("expected %v but got %v", expected, actual)
+		}
+	}
+}
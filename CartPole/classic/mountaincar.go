@@ -0,0 +1,39 @@
+package classic
+
+import "math"
+
+const (
+	mountainCarMinPos   = -1.2
+	mountainCarMaxPos   = 0.6
+	mountainCarMaxSpeed = 0.07
+	mountainCarGoalPos  = 0.5
+	mountainCarForce    = 0.001
+	mountainCarGravity  = 0.0025
+)
+
+// mountainCar implements MountainCar-v0.
+type mountainCar struct {
+	position float64
+	velocity float64
+}
+
+func (m *mountainCar) Reset(r *random) {
+	m.position = r.Uniform(-0.6, -0.4)
+	m.velocity = 0
+}
+
+func (m *mountainCar) Step(action []float64) (reward float64, done bool) {
+	m.velocity += (action[0]-1)*mountainCarForce +
+		math.Cos(3*m.position)*(-mountainCarGravity)
+	m.velocity = clip(m.velocity, -mountainCarMaxSpeed, mountainCarMaxSpeed)
+	m.position += m.velocity
+	m.position = clip(m.position, mountainCarMinPos, mountainCarMaxPos)
+	if m.position == mountainCarMinPos && m.velocity < 0 {
+		m.velocity = 0
+	}
+	return -1, m.position >= mountainCarGoalPos
+}
+
+func (m *mountainCar) Observe() []float64 {
+	return []float64{m.position, m.velocity}
+}
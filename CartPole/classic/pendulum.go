@@ -0,0 +1,48 @@
+package classic
+
+import "math"
+
+const (
+	pendulumMaxSpeed  = 8
+	pendulumMaxTorque = 2
+	pendulumDt        = 0.05
+	pendulumGravity   = 10.0
+	pendulumMass      = 1.0
+	pendulumLength    = 1.0
+)
+
+// pendulum implements Pendulum-v0.
+type pendulum struct {
+	theta    float64
+	thetaDot float64
+}
+
+func (p *pendulum) Reset(r *random) {
+	p.theta = r.Uniform(-math.Pi, math.Pi)
+	p.thetaDot = r.Uniform(-1, 1)
+}
+
+func (p *pendulum) Step(action []float64) (reward float64, done bool) {
+	u := clip(action[0], -pendulumMaxTorque, pendulumMaxTorque)
+	th, thDot := p.theta, p.thetaDot
+	cost := math.Pow(angleNormalize(th), 2) + 0.1*thDot*thDot + 0.001*u*u
+
+	newThDot := thDot + (-3*pendulumGravity/(2*pendulumLength)*math.Sin(th+math.Pi)+
+		3/(pendulumMass*pendulumLength*pendulumLength)*u)*pendulumDt
+	p.theta = th + newThDot*pendulumDt
+	p.thetaDot = clip(newThDot, -pendulumMaxSpeed, pendulumMaxSpeed)
+	return -cost, false
+}
+
+func (p *pendulum) Observe() []float64 {
+	return []float64{math.Cos(p.theta), math.Sin(p.theta), p.thetaDot}
+}
+
+// angleNormalize maps an angle to [-pi, pi).
+func angleNormalize(x float64) float64 {
+	x = math.Mod(x+math.Pi, 2*math.Pi)
+	if x < 0 {
+		x += 2 * math.Pi
+	}
+	return x - math.Pi
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+// Dyna produces policy gradient batches which mix real
+// transitions with imagined ones.
+//
+// Imagined rollouts are short branches which start from
+// states visited in real episodes, so the models are only
+// trusted near data they were trained on.
+type Dyna struct {
+	Dynamics *Dynamics
+	Policy   Policy
+
+	// BatchSize is the number of transitions per batch.
+	BatchSize int
+
+	// RealFrac is the fraction of each batch which comes
+	// from real episodes.
+	RealFrac float64
+
+	// BranchLength is the maximum number of steps in an
+	// imagined branch.
+	BranchLength int
+}
+
+// Batch creates a batch from the real episodes.
+//
+// Each transition is weighted by its reward-to-go: to the
+// end of the episode for real transitions, and to the end
+// of the branch for imagined ones.
+// Since these scales differ, the weights are normalized
+// separately for real and imagined transitions.
+func (d *Dyna) Batch(trials []*Trial) (obs, actions, weights anyvec.Vector) {
+	numReal := int(math.Floor(float64(d.BatchSize)*d.RealFrac + 0.5))
+	numImagined := d.BatchSize - numReal
+
+	var obsData, actionData []float64
+	var realWeights, imaginedWeights []float64
+
+	for i := 0; i < numReal; i++ {
+		trial, t := randomTransition(trials)
+		obsData = append(obsData, trial.Observations[t]...)
+		actionData = append(actionData, trial.Actions[t]...)
+		realWeights = append(realWeights, rewardToGo(trial.Rewards)[t])
+	}
+
+	// Branches may be truncated before their first step,
+	// so the number of attempts is bounded.
+	for attempt := 0; attempt < numImagined && len(imaginedWeights) < numImagined; attempt++ {
+		trial, t := randomTransition(trials)
+		state := trial.Observations[t]
+		var rewards []float64
+		for len(rewards) < d.BranchLength && len(imaginedWeights)+len(rewards) < numImagined {
+			action := d.Policy.Act(anyvec64.MakeVectorData(state)).Data().([]float64)
+			next, reward, done, disagreement := d.Dynamics.Step(state, [][]float64{action})
+			if d.Dynamics.Truncated(disagreement[0]) {
+				break
+			}
+			obsData = append(obsData, state...)
+			actionData = append(actionData, action...)
+			rewards = append(rewards, reward[0])
+			state = next
+			if rand.Float64() < done[0] {
+				break
+			}
+		}
+		imaginedWeights = append(imaginedWeights, rewardToGo(rewards)...)
+	}
+
+	normalize(realWeights)
+	normalize(imaginedWeights)
+	size := actionSize(d.Dynamics.Spec)
+	var weightData []float64
+	for _, w := range append(realWeights, imaginedWeights...) {
+		for i := 0; i < size; i++ {
+			weightData = append(weightData, w)
+		}
+	}
+	return anyvec64.MakeVectorData(obsData), anyvec64.MakeVectorData(actionData),
+		anyvec64.MakeVectorData(weightData)
+}
+
+// randomTransition selects a uniformly random timestep
+// from a set of episodes.
+func randomTransition(trials []*Trial) (*Trial, int) {
+	var total int
+	for _, t := range trials {
+		total += len(t.Rewards)
+	}
+	idx := rand.Intn(total)
+	for _, t := range trials {
+		if idx < len(t.Rewards) {
+			return t, idx
+		}
+		idx -= len(t.Rewards)
+	}
+	panic("unreachable")
+}
+
+func rewardToGo(rewards []float64) []float64 {
+	res := make([]float64, len(rewards))
+	var sum float64
+	for i := len(rewards) - 1; i >= 0; i-- {
+		sum += rewards[i]
+		res[i] = sum
+	}
+	return res
+}
+
+// normalize scales values to have zero mean and unit
+// variance.
+func normalize(x []float64) {
+	if len(x) == 0 {
+		return
+	}
+	var mean, sqMean float64
+	for _, v := range x {
+		mean += v
+		sqMean += v * v
+	}
+	mean /= float64(len(x))
+	sqMean /= float64(len(x))
+	std := math.Sqrt(math.Max(0, sqMean-mean*mean))
+	for i, v := range x {
+		x[i] = (v - mean) / math.Max(std, 1e-8)
+	}
+}
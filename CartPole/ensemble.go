@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+const (
+	ModelStepSize = 0.001
+
+	// MaxLogVar bounds the predicted log variances when
+	// sampling, so that an untrained model cannot produce
+	// wild observations.
+	MaxLogVar = 2
+)
+
+// A ProbModel predicts a diagonal Gaussian distribution
+// over outputs.
+type ProbModel struct {
+	Body   anynet.Net
+	Mean   anynet.Layer
+	LogVar anynet.Layer
+}
+
+// NewProbModel creates a ProbModel with one hidden layer.
+func NewProbModel(c anyvec.Creator, inSize, hiddenSize, outSize int) *ProbModel {
+	return &ProbModel{
+		Body: anynet.Net{
+			anynet.NewFC(c, inSize, hiddenSize),
+			anynet.Tanh,
+		},
+		Mean:   anynet.NewFC(c, hiddenSize, outSize),
+		LogVar: anynet.NewFC(c, hiddenSize, outSize),
+	}
+}
+
+// Parameters returns the model's parameters.
+func (p *ProbModel) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(p.Body, p.Mean, p.LogVar)
+}
+
+// Apply computes the means and log variances for a batch.
+func (p *ProbModel) Apply(in anydiff.Res, n int) (mean, logVar anydiff.Res) {
+	hidden := p.Body.Apply(in, n)
+	return p.Mean.Apply(hidden, n), p.LogVar.Apply(hidden, n)
+}
+
+// Cost computes the mean Gaussian negative log-likelihood
+// of a batch of targets, dropping constant terms.
+func (p *ProbModel) Cost(in, target anyvec.Vector, n int) anydiff.Res {
+	c := in.Creator()
+	mean, logVar := p.Apply(anydiff.NewConst(in), n)
+	diff := anydiff.Sub(mean, anydiff.NewConst(target))
+	invVar := anydiff.Exp(anydiff.Scale(logVar, c.MakeNumeric(-1)))
+	nll := anydiff.Add(anydiff.Mul(anydiff.Square(diff), invVar), logVar)
+	return anydiff.Scale(anydiff.Sum(nll), c.MakeNumeric(0.5/float64(n)))
+}
+
+// An Ensemble is a set of ProbModels trained on different
+// bootstrap samples of the same data.
+//
+// Where the models disagree, the data did not pin down the
+// dynamics, so predictions there should not be trusted.
+type Ensemble struct {
+	Models []*ProbModel
+
+	// TargetVars is the variance of each output component
+	// in the training data, which is used to normalize the
+	// disagreement.
+	// It is set by Train.
+	TargetVars []float64
+}
+
+// NewEnsemble creates an ensemble of n models.
+func NewEnsemble(c anyvec.Creator, n, inSize, hiddenSize, outSize int) *Ensemble {
+	res := &Ensemble{}
+	for i := 0; i < n; i++ {
+		res.Models = append(res.Models, NewProbModel(c, inSize, hiddenSize, outSize))
+	}
+	return res
+}
+
+// Train fits every model to its own bootstrap sample of
+// the training data, using the validation data for early
+// stopping.
+//
+// It fails if there is no training data.
+func (e *Ensemble) Train(train, valid anyff.SliceSampleList, cfg FitConfig) error {
+	if len(train) == 0 {
+		return errors.New("train ensemble: no training samples")
+	}
+	losses := make([]float64, len(e.Models))
+	epochs := make([]int, len(e.Models))
+	for i, model := range e.Models {
+		bootstrap := make(anyff.SliceSampleList, len(train))
+		for j := range bootstrap {
+			bootstrap[j] = train[rand.Intn(len(train))]
+		}
+		losses[i], epochs[i] = Fit(&probFitter{Model: model}, bootstrap, valid, cfg)
+	}
+	e.TargetVars = targetVariances(train)
+	log.Printf("ensemble validation losses: %v (epochs: %v)", losses, epochs)
+	return nil
+}
+
+// Predict computes every model's means and variances for a
+// batch of inputs.
+func (e *Ensemble) Predict(in anyvec.Vector, n int) (means, vars [][]float64) {
+	for _, model := range e.Models {
+		mean, logVar := model.Apply(anydiff.NewConst(in), n)
+		means = append(means, mean.Output().Data().([]float64))
+		variances := append([]float64{}, logVar.Output().Data().([]float64)...)
+		for i, x := range variances {
+			variances[i] = math.Exp(math.Min(x, MaxLogVar))
+		}
+		vars = append(vars, variances)
+	}
+	return
+}
+
+// Sample samples outputs for a batch of inputs, using a
+// random model for each input.
+//
+// It also returns the disagreement for each input: the
+// variance of the models' means, averaged over the output
+// components.
+// Each component's variance is divided by its variance in
+// the training data (see TargetVars), so the disagreement
+// does not depend on the scale of the observations.
+func (e *Ensemble) Sample(in anyvec.Vector, n int) (out, disagreement []float64) {
+	means, vars := e.Predict(in, n)
+	outSize := len(means[0]) / n
+	out = make([]float64, len(means[0]))
+	disagreement = make([]float64, n)
+	for i := 0; i < n; i++ {
+		model := rand.Intn(len(e.Models))
+		for j := i * outSize; j < (i+1)*outSize; j++ {
+			out[j] = means[model][j] + rand.NormFloat64()*math.Sqrt(vars[model][j])
+
+			var mean, sqMean float64
+			for _, m := range means {
+				mean += m[j]
+				sqMean += m[j] * m[j]
+			}
+			mean /= float64(len(means))
+			sqMean /= float64(len(means))
+			variance := sqMean - mean*mean
+			if e.TargetVars != nil {
+				variance /= e.TargetVars[j-i*outSize]
+			}
+			disagreement[i] += variance / float64(outSize)
+		}
+	}
+	return
+}
+
+// targetVariances computes the variance of each output
+// component, with a floor for constant components.
+func targetVariances(samples anyff.SliceSampleList) []float64 {
+	if len(samples) == 0 {
+		return nil
+	}
+	_, out := sampleBatch(samples)
+	data := out.Data().([]float64)
+	outSize := len(data) / len(samples)
+	means := make([]float64, outSize)
+	res := make([]float64, outSize)
+	for i, x := range data {
+		means[i%outSize] += x / float64(len(samples))
+	}
+	for i, x := range data {
+		d := x - means[i%outSize]
+		res[i%outSize] += d * d / float64(len(samples))
+	}
+	for i, x := range res {
+		res[i] = math.Max(x, 1e-8)
+	}
+	return res
+}
+
+func sampleBatch(samples anyff.SliceSampleList) (in, out anyvec.Vector) {
+	var inData, outData []float64
+	for _, s := range samples {
+		inData = append(inData, s.Input.Data().([]float64)...)
+		outData = append(outData, s.Output.Data().([]float64)...)
+	}
+	return anyvec64.MakeVectorData(inData), anyvec64.MakeVectorData(outData)
+}
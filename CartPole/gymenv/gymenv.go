@@ -0,0 +1,73 @@
+// Package gymenv exposes gym-http-api environments through
+// the classic.Env interface, so that agents can switch
+// between gym and the pure Go environments.
+package gymenv
+
+import (
+	"errors"
+
+	gym "github.com/openai/gym-http-api/binding-go"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/rl-agents/CartPole/classic"
+)
+
+// Env is a classic.Env backed by a gym-http-api
+// environment.
+type Env struct {
+	Client  *gym.Client
+	ID      gym.InstanceID
+	EnvSpec *classic.Spec
+}
+
+// Make creates an environment on a gym-http-api server.
+//
+// Only environments with a spec in classic.Specs are
+// supported.
+func Make(baseURL, name string) (*Env, error) {
+	spec, ok := classic.Specs[name]
+	if !ok {
+		return nil, errors.New("make gym environment: unsupported name: " + name)
+	}
+	client, err := gym.NewClient(baseURL)
+	if err != nil {
+		return nil, essentials.AddCtx("make gym environment", err)
+	}
+	id, err := client.Create(name)
+	if err != nil {
+		return nil, essentials.AddCtx("make gym environment", err)
+	}
+	return &Env{Client: client, ID: id, EnvSpec: spec}, nil
+}
+
+func (e *Env) Spec() *classic.Spec {
+	return e.EnvSpec
+}
+
+func (e *Env) Reset() (anyvec.Vector, error) {
+	obs, err := e.Client.Reset(e.ID)
+	if err != nil {
+		return nil, err
+	}
+	return anyvec64.MakeVectorData(obs.([]float64)), nil
+}
+
+func (e *Env) Step(action anyvec.Vector) (anyvec.Vector, float64, bool, error) {
+	var gymAction interface{}
+	if e.EnvSpec.Discrete() {
+		gymAction = anyvec.MaxIndex(action)
+	} else {
+		gymAction = action.Data().([]float64)
+	}
+	obs, reward, done, _, err := e.Client.Step(e.ID, gymAction, false)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return anyvec64.MakeVectorData(obs.([]float64)), reward, done, nil
+}
+
+// Close closes the environment on the server.
+func (e *Env) Close() error {
+	return e.Client.Close(e.ID)
+}
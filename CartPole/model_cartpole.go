@@ -1,22 +1,27 @@
 // Use model-based reinforcement learning to solve
-// CartPole in as few episodes as possible.
+// CartPole and other low-dimensional environments in as
+// few episodes as possible.
 
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
-	"math"
 	"math/rand"
 	"time"
 
-	gym "github.com/openai/gym-http-api/binding-go"
 	"github.com/unixpickle/anydiff"
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyff"
 	"github.com/unixpickle/anynet/anysgd"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/essentials"
 	"github.com/unixpickle/rip"
+	"github.com/unixpickle/rl-agents/CartPole/classic"
+	"github.com/unixpickle/rl-agents/CartPole/gymenv"
+	"github.com/unixpickle/rl-agents/monitor"
 )
 
 const (
@@ -24,213 +29,371 @@ const (
 	MonitorDir = "/tmp/cartpole-monitor"
 )
 
-const (
-	PolicyEpisodes = 10
-	MaxReward      = 500
-)
+const PolicyEpisodes = 10
 
 func main() {
-	client, err := gym.NewClient(BaseURL)
-	must(err)
+	var envName string
+	var monitorDir string
+	var monitorAppend bool
+	var native bool
+	var solveReward float64
+	var mode string
+	var plannerName string
+	var horizon int
+	var candidates int
+	var elites int
+	var cemIters int
+	var ensembleSize int
+	var maxDisagreement float64
+	var bonus float64
+	var seed int64
+	var validFrac float64
+	var modelEpochs int
+	var patience int
+	var policyIters int
+	var dynaBatch int
+	var realFrac float64
+	var branchLength int
+	flag.StringVar(&envName, "env", "CartPole-v1", "environment name")
+	flag.BoolVar(&native, "native", false, "use a Go environment instead of gym")
+	flag.StringVar(&monitorDir, "monitor", MonitorDir, "run directory for the episode log")
+	flag.BoolVar(&monitorAppend, "monitor-append", false,
+		"continue an existing episode log instead of refusing to start")
+	flag.Float64Var(&solveReward, "solve-reward", 500,
+		"log the first episode with at least this reward "+
+			"(see monitor_summary for windowed solve times)")
+	flag.StringVar(&mode, "mode", "policy", "control mode (policy, mpc, dyna)")
+	flag.StringVar(&plannerName, "planner", "cem", "MPC planner (shooting, cem)")
+	flag.IntVar(&horizon, "horizon", 30, "MPC planning horizon")
+	flag.IntVar(&candidates, "candidates", 200, "MPC action sequences per iteration")
+	flag.IntVar(&elites, "elites", 20, "CEM elite sequences")
+	flag.IntVar(&cemIters, "cem-iters", 3, "CEM iterations")
+	flag.IntVar(&ensembleSize, "ensemble", 5, "number of dynamics models")
+	flag.Float64Var(&maxDisagreement, "max-disagreement", 0.05,
+		"ensemble disagreement, relative to the observation variance, which truncates "+
+			"imagined rollouts (0 for none)")
+	flag.Float64Var(&bonus, "bonus", 0, "exploration bonus per unit of disagreement")
+	flag.Int64Var(&seed, "seed", time.Now().UnixNano(), "random seed (for -native runs)")
+	flag.Float64Var(&validFrac, "valid-frac", 0.1, "fraction of transitions held out for validation")
+	flag.IntVar(&modelEpochs, "model-epochs", 50, "maximum epochs per model fit")
+	flag.IntVar(&patience, "patience", 3, "epochs without validation improvement before stopping")
+	flag.IntVar(&policyIters, "policy-iters", 200, "policy updates per round")
+	flag.IntVar(&dynaBatch, "dyna-batch", 200, "transitions per Dyna policy update")
+	flag.Float64Var(&realFrac, "real-frac", 0.5, "fraction of real transitions in Dyna batches")
+	flag.IntVar(&branchLength, "branch", 5, "length of imagined Dyna branches")
+	flag.Parse()
+
+	log.Println("random seed:", seed)
+	rand.Seed(seed)
+
+	var planner Planner
+	switch plannerName {
+	case "shooting":
+		planner = &RandomShooting{Horizon: horizon, Candidates: candidates}
+	case "cem":
+		planner = &CEM{
+			Horizon:    horizon,
+			Candidates: candidates,
+			Elites:     elites,
+			Iters:      cemIters,
+			Smoothing:  0.1,
+		}
+	default:
+		essentials.Die("unknown planner:", plannerName)
+	}
+	if horizon < 1 || candidates < 1 {
+		essentials.Die("-horizon and -candidates must be at least 1")
+	}
+	if plannerName == "cem" && (elites < 1 || elites > candidates) {
+		essentials.Die("-elites must be between 1 and -candidates")
+	}
+	if plannerName == "cem" && cemIters < 1 {
+		essentials.Die("-cem-iters must be at least 1")
+	}
+	if validFrac < 0 || validFrac >= 1 {
+		essentials.Die("-valid-frac must be in [0, 1)")
+	}
+	if mode != "policy" && mode != "mpc" && mode != "dyna" {
+		essentials.Die("unknown mode:", mode)
+	}
 
-	id, err := client.Create("CartPole-v1")
-	must(err)
+	// Appending would merge two runs into one log, so the
+	// episodes-to-solve count would cover both of them.
+	if !monitorAppend {
+		if episodes, err := monitor.ReadEpisodes(monitorDir); err == nil && len(episodes) > 0 {
+			essentials.Die(fmt.Sprintf("%s already has %d episodes "+
+				"(use another -monitor directory or -monitor-append)", monitorDir,
+				len(episodes)))
+		}
+	}
 
 	c := anyvec64.CurrentCreator()
 
-	// Policy takes an observation and outputs log probs for
-	// the two moves.
-	policy := anynet.Net{
-		anynet.NewFC(c, 4, 2),
-		anynet.LogSoftmax,
+	var env classic.Env
+	var err error
+	if native {
+		env, err = classic.Make(envName, c, nil)
+		must(err)
+	} else {
+		gymEnv, err := gymenv.Make(BaseURL, envName)
+		must(err)
+		defer gymEnv.Close()
+		env = gymEnv
 	}
+	spec := env.Spec()
+	monitorEnv, err := monitor.NewEnv(env, monitorDir)
+	must(err)
+	defer monitorEnv.Close()
+	env = classic.WithSpec(monitorEnv, spec)
+	modelInSize := spec.ObsSize + actionSize(spec)
 
-	// Takes an observation + action and predicts next
-	// observation.
-	nextModel := anynet.Net{
-		anynet.NewFC(c, 5, 30),
+	policy := NewPolicy(c, spec)
+
+	// Takes an observation + action and predicts a
+	// distribution over the next observation.
+	nextModel := NewEnsemble(c, ensembleSize, modelInSize, 30, spec.ObsSize)
+
+	// Like nextModel, but predicts the reward.
+	rewardModel := anynet.Net{
+		anynet.NewFC(c, modelInSize, 30),
 		anynet.Tanh,
-		anynet.NewFC(c, 30, 4),
+		anynet.NewFC(c, 30, 1),
 	}
 
 	// Like nextModel, but predicts (pre-sigmoid) probability
 	// for the episode ending.
 	doneModel := anynet.Net{
-		anynet.NewFC(c, 5, 30),
+		anynet.NewFC(c, modelInSize, 30),
 		anynet.Tanh,
 		anynet.NewFC(c, 30, 1),
 	}
+	dynamics := &Dynamics{
+		Spec:            spec,
+		Next:            nextModel,
+		Reward:          rewardModel,
+		Done:            doneModel,
+		MaxDisagreement: maxDisagreement,
+		Bonus:           bonus,
+	}
+
+	// Choose actions with the policy network, or plan them
+	// with the models.
+	act := policy.Act
+	if mode == "mpc" {
+		act = func(obs anyvec.Vector) anyvec.Vector {
+			return planner.Plan(dynamics, obs)
+		}
+	}
 
-	log.Println("Press Ctrl+C to stop and upload to Gym.")
-	must(client.StartMonitor(id, MonitorDir, true, false, false))
+	log.Println("Press Ctrl+C to stop.")
 	waiter := rip.NewRIP()
 
-	var nextData, doneData anyff.SliceSampleList
+	fitConfig := FitConfig{BatchSize: 30, MaxEpochs: modelEpochs, Patience: patience}
+	nextData := &Dataset{ValidFrac: validFrac}
+	rewardData := &Dataset{ValidFrac: validFrac}
+	doneData := &Dataset{ValidFrac: validFrac}
 	var starts []anyvec.Vector
-	for !waiter.Done() {
+	var trials []*Trial
+	dyna := &Dyna{
+		Dynamics:     dynamics,
+		Policy:       policy,
+		BatchSize:    dynaBatch,
+		RealFrac:     realFrac,
+		BranchLength: branchLength,
+	}
+	var reachedReward bool
+	for round := 0; !waiter.Done(); round++ {
+		monitorEnv.SetCheckpoint(fmt.Sprintf("round-%d", round))
 		for i := 0; i < 5; i++ {
 			log.Println("episode", len(starts))
-			start, nextSamples, doneSamples := runTrial(client, id, policy)
-			nextData = append(nextData, nextSamples...)
-			doneData = append(doneData, doneSamples...)
-			starts = append(starts, start)
+			trial := runTrial(env, act)
+			nextData.Add(trial.NextSamples)
+			rewardData.Add(trial.RewardSamples)
+			doneData.Add(trial.DoneSamples)
+			starts = append(starts, trial.Start)
+			trials = append(trials, trial)
+			if trial.TotalReward >= solveReward && !reachedReward {
+				log.Printf("first episode with reward >= %g: episode %d", solveReward,
+					len(starts))
+				reachedReward = true
+			}
 		}
-		trainModel(nextData, nextModel, anynet.MSE{})
-		trainModel(doneData, doneModel, anynet.SigmoidCE{})
-		if len(starts)%5 == 0 {
-			trainPolicy(starts, nextModel, doneModel, policy)
+		if err := nextModel.Train(nextData.Train, nextData.Valid, fitConfig); err != nil {
+			// Only possible with very little data, since
+			// -valid-frac is below 1.
+			log.Println(err)
+		}
+		trainModel(rewardData, rewardModel, anynet.MSE{}, fitConfig)
+		trainModel(doneData, doneModel, anynet.SigmoidCE{}, fitConfig)
+		if mode == "policy" && len(starts)%5 == 0 {
+			var modeledReward float64
+			trainPolicy(policy, spec.ObsSize, policyIters, func() (obs, actions,
+				weights anyvec.Vector) {
+				obs, actions, weights, modeledReward = sampleModel(starts, dynamics, policy)
+				return
+			})
+			log.Printf("modeled reward: %f", modeledReward)
+		} else if mode == "dyna" {
+			trainPolicy(policy, spec.ObsSize, policyIters, func() (obs, actions,
+				weights anyvec.Vector) {
+				return dyna.Batch(trials)
+			})
 		}
 	}
+}
 
-	must(client.CloseMonitor(id))
-	must(client.Close(id))
-
-	// Set OPENAI_GYM_API_KEY env var.
-	must(client.Upload(MonitorDir, "", ""))
+// A Trial is a real episode, recorded as training data for
+// the models.
+type Trial struct {
+	Start       anyvec.Vector
+	TotalReward float64
+
+	// Observations, Actions, and Rewards record each
+	// timestep of the episode.
+	Observations [][]float64
+	Actions      [][]float64
+	Rewards      []float64
+
+	NextSamples   anyff.SliceSampleList
+	RewardSamples anyff.SliceSampleList
+	DoneSamples   anyff.SliceSampleList
 }
 
-func runTrial(c *gym.Client, id gym.InstanceID, policy anynet.Layer) (start anyvec.Vector,
-	nextSamples, doneSamples anyff.SliceSampleList) {
-	obs, err := c.Reset(id)
+func runTrial(env classic.Env, act func(obs anyvec.Vector) anyvec.Vector) *Trial {
+	obs, err := env.Reset()
 	must(err)
-	start = anyvec64.MakeVectorData(obs.([]float64))
-	var totalReward float64
-	for {
-		policyIn := anyvec64.MakeVectorData(obs.([]float64))
-		policyOut := policy.Apply(anydiff.NewConst(policyIn), 1).Output()
-		action := selectAction(policyOut)
+	trial := &Trial{Start: obs}
+	for t := 1; true; t++ {
+		action := act(obs)
+		modelIn := modelInput(obs, action)
+		trial.Observations = append(trial.Observations, obs.Data().([]float64))
 
 		var done bool
 		var reward float64
-		obs, reward, done, _, err = c.Step(id, action, false)
+		obs, reward, done, err = env.Step(action)
 		must(err)
 
-		totalReward += reward
+		trial.TotalReward += reward
+		trial.Actions = append(trial.Actions, action.Data().([]float64))
+		trial.Rewards = append(trial.Rewards, reward)
 
-		nextSamples = append(nextSamples, &anyff.Sample{
-			Input:  modelInput(policyIn, action),
-			Output: anyvec64.MakeVectorData(obs.([]float64)),
+		trial.NextSamples = append(trial.NextSamples, &anyff.Sample{
+			Input:  modelIn,
+			Output: obs,
+		})
+		trial.RewardSamples = append(trial.RewardSamples, &anyff.Sample{
+			Input:  modelIn,
+			Output: anyvec64.MakeVectorData([]float64{reward}),
 		})
 
-		if reward != MaxReward {
-			doneSamples = append(doneSamples, &anyff.Sample{
-				Input:  modelInput(policyIn, action),
+		// Hitting the time limit is not a real terminal
+		// state, so it should not teach the done model.
+		if t < env.Spec().MaxSteps {
+			trial.DoneSamples = append(trial.DoneSamples, &anyff.Sample{
+				Input:  modelIn,
 				Output: anyvec64.MakeVectorData([]float64{boolToFloat(done)}),
 			})
 		}
 
 		if done {
-			log.Printf("actual reward: %f", totalReward)
-			return
+			log.Printf("actual reward: %f", trial.TotalReward)
+			return trial
 		}
 	}
+	panic("unreachable")
 }
 
-func trainModel(samples anysgd.SampleList, model anynet.Net, cost anynet.Cost) {
-	timeout := make(chan struct{})
-	go func() {
-		time.Sleep(time.Second * 4)
-		close(timeout)
-	}()
-
-	tr := &anyff.Trainer{
-		Net:    model,
-		Params: model.Parameters(),
-		Cost:   cost,
-	}
-	sgd := &anysgd.SGD{
-		Fetcher:     tr,
-		Gradienter:  tr,
-		Transformer: &anysgd.Adam{},
-		Samples:     samples,
-		BatchSize:   30,
-		Rater:       anysgd.ConstRater(0.001),
-	}
-	sgd.Run(timeout)
-
-	log.Printf("model %T cost: %v", cost, tr.LastCost)
+func trainModel(data *Dataset, model anynet.Net, cost anynet.Cost, cfg FitConfig) {
+	loss, epochs := Fit(newNetFitter(model, cost), data.Train, data.Valid, cfg)
+	log.Printf("model %T validation cost: %f (epochs: %d)", cost, loss, epochs)
 }
 
-func trainPolicy(starts []anyvec.Vector, nextModel, doneModel, policy anynet.Net) {
-	timeout := time.After(time.Second * 8)
-
-	tr := &anyff.Trainer{
-		Net:     policy,
-		Params:  policy.Parameters(),
-		Cost:    anynet.DotCost{},
-		Average: true,
-	}
+// trainPolicy runs policy gradient updates on batches from
+// the sample function.
+//
+// Each batch holds observations, actions, and a weight for
+// every log-probability term of the actions.
+func trainPolicy(policy Policy, obsSize, iters int,
+	sample func() (obs, actions, weights anyvec.Vector)) {
 	var adam anysgd.Adam
-
-	for {
-		samples, modeledReward := sampleModel(starts, nextModel, doneModel, policy)
-
-		select {
-		case <-timeout:
-			log.Printf("modeled reward: %f", modeledReward)
-			return
-		default:
+	for i := 0; i < iters; i++ {
+		obs, actions, weights := sample()
+		if weights.Len() == 0 {
+			continue
 		}
-
-		batch, _ := tr.Fetch(samples)
-		grad := adam.Transform(tr.Gradient(batch))
+		n := obs.Len() / obsSize
+		c := obs.Creator()
+		logProbs := policy.LogProbs(obs, actions, n)
+		objective := anydiff.Sum(anydiff.Mul(logProbs, anydiff.NewConst(weights)))
+		cost := anydiff.Scale(objective, c.MakeNumeric(-1/float64(n)))
+
+		grad := anydiff.NewGrad(policy.Parameters()...)
+		cost.Propagate(anyvec64.MakeVectorData([]float64{1}), grad)
+		grad = adam.Transform(grad)
 		grad.Scale(-0.001)
 		grad.AddToVars()
 	}
 }
 
-func sampleModel(starts []anyvec.Vector, nextModel, doneModel,
-	policy anynet.Net) (anyff.SliceSampleList, float64) {
-	var samples anyff.SliceSampleList
+// sampleModel simulates episodes in the model and produces
+// a batch of observations and actions, plus policy
+// gradient weights for the action log-probability terms.
+//
+// The weights are the episode returns, normalized across
+// the batch to act as a baseline, so environments with
+// all-negative rewards (e.g. Pendulum) still get a useful
+// gradient.
+func sampleModel(starts []anyvec.Vector, dynamics *Dynamics,
+	policy Policy) (obs, actions, weights anyvec.Vector, meanReward float64) {
+	var obsData, actionData []float64
+	var returns []float64
+	var actionCounts []int
 	var totalReward float64
 	for i := 0; i < PolicyEpisodes; i++ {
 		state := starts[rand.Intn(len(starts))]
-		var inputs []anyvec.Vector
-		var outMasks []anyvec.Vector
+		var episodeObs, episodeActions []float64
 		var reward float64
 
-		for reward < MaxReward {
-			inputs = append(inputs, state.Copy())
-			policyOut := policy.Apply(anydiff.NewConst(state), 1).Output()
-			action := selectAction(policyOut)
-			mask := make([]float64, 2)
-			mask[action] = 1
-			outMasks = append(outMasks, anyvec64.MakeVectorData(mask))
-			reward++
-
-			modelIn := anydiff.NewConst(modelInput(state, action))
-			state = nextModel.Apply(modelIn, 1).Output()
-			done := anynet.Sigmoid.Apply(doneModel.Apply(modelIn, 1), 1).Output()
+		for t := 0; t < dynamics.Spec.MaxSteps; t++ {
+			action := policy.Act(state).Data().([]float64)
+			next, rewards, done, disagreement := dynamics.Step(state.Data().([]float64),
+				[][]float64{action})
+			if dynamics.Truncated(disagreement[0]) {
+				break
+			}
+			episodeObs = append(episodeObs, state.Data().([]float64)...)
+			episodeActions = append(episodeActions, action...)
+			reward += rewards[0]
+			state = anyvec64.MakeVectorData(next)
 
-			if rand.Float64() < done.Data().([]float64)[0] {
+			if rand.Float64() < done[0] {
 				break
 			}
 		}
 
 		totalReward += reward
-		for i, x := range outMasks {
-			x.Scale(reward)
-			samples = append(samples, &anyff.Sample{
-				Input:  inputs[i],
-				Output: x,
-			})
+		if len(episodeActions) == 0 {
+			continue
 		}
+		obsData = append(obsData, episodeObs...)
+		actionData = append(actionData, episodeActions...)
+		returns = append(returns, reward)
+		actionCounts = append(actionCounts, len(episodeActions))
 	}
-	return samples, totalReward / PolicyEpisodes
-}
 
-func modelInput(state anyvec.Vector, action int) anyvec.Vector {
-	actionVec := anyvec64.MakeVectorData([]float64{float64(action)})
-	return anyvec64.Concat(state, actionVec)
+	normalize(returns)
+	var weightData []float64
+	for i, r := range returns {
+		for j := 0; j < actionCounts[i]; j++ {
+			weightData = append(weightData, r)
+		}
+	}
+	return anyvec64.MakeVectorData(obsData), anyvec64.MakeVectorData(actionData),
+		anyvec64.MakeVectorData(weightData), totalReward / PolicyEpisodes
 }
 
-func selectAction(probs anyvec.Vector) int {
-	vals := probs.Data().([]float64)
-	if math.Exp(float64(vals[0])) > rand.Float64() {
-		return 0
-	} else {
-		return 1
-	}
+func modelInput(state, action anyvec.Vector) anyvec.Vector {
+	return anyvec64.Concat(state, action)
 }
 
 func boolToFloat(b bool) float64 {
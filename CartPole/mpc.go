@@ -0,0 +1,251 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/rl-agents/CartPole/classic"
+)
+
+// Dynamics is a learned model of the environment.
+type Dynamics struct {
+	Spec *classic.Spec
+
+	// Next predicts the next observation.
+	Next *Ensemble
+
+	// Reward predicts the reward for a transition.
+	Reward anynet.Net
+
+	// Done predicts the (pre-sigmoid) probability that the
+	// episode ends.
+	Done anynet.Net
+
+	// MaxDisagreement, if non-zero, is the ensemble
+	// disagreement past which simulated rollouts are
+	// truncated.
+	// Disagreement is relative to the variance of the
+	// training data (see Ensemble.Sample), so one threshold
+	// works across environments.
+	MaxDisagreement float64
+
+	// Bonus scales the ensemble disagreement into an
+	// exploration bonus added to each simulated reward.
+	Bonus float64
+}
+
+// Step simulates one timestep for a batch of states.
+//
+// It returns the next states, the rewards (including the
+// exploration bonus), the probability that each episode
+// ended, and the ensemble disagreement at each state.
+func (d *Dynamics) Step(states []float64, actions [][]float64) (next, rewards,
+	doneProbs, disagreement []float64) {
+	n := len(actions)
+	in := modelInputBatch(states, actions)
+	next, disagreement = d.Next.Sample(in, n)
+	rewards = d.Reward.Apply(anydiff.NewConst(in), n).Output().Data().([]float64)
+	doneLogits := d.Done.Apply(anydiff.NewConst(in), n).Output().Data().([]float64)
+	doneProbs = make([]float64, n)
+	for i, logit := range doneLogits {
+		rewards[i] += d.Bonus * disagreement[i]
+		doneProbs[i] = sigmoid(logit)
+	}
+	return
+}
+
+// Truncated checks if a simulated rollout should be cut
+// off because the models disagree too much.
+func (d *Dynamics) Truncated(disagreement float64) bool {
+	return d.MaxDisagreement != 0 && disagreement > d.MaxDisagreement
+}
+
+// ExpectedRewards simulates action sequences from a start
+// state and returns the expected reward of each one.
+//
+// Each sequence is a list of action vectors.
+func (d *Dynamics) ExpectedRewards(start anyvec.Vector, seqs [][][]float64) []float64 {
+	n := len(seqs)
+	startData := start.Data().([]float64)
+	states := make([]float64, 0, n*len(startData))
+	for i := 0; i < n; i++ {
+		states = append(states, startData...)
+	}
+
+	alive := make([]float64, n)
+	totals := make([]float64, n)
+	for i := range alive {
+		alive[i] = 1
+	}
+	for t := 0; t < len(seqs[0]); t++ {
+		actions := make([][]float64, n)
+		for i, seq := range seqs {
+			actions[i] = seq[t]
+		}
+		var rewards, doneProbs, disagreement []float64
+		states, rewards, doneProbs, disagreement = d.Step(states, actions)
+		for i, p := range doneProbs {
+			if d.Truncated(disagreement[i]) {
+				alive[i] = 0
+			}
+			totals[i] += alive[i] * rewards[i]
+			alive[i] *= 1 - p
+		}
+	}
+	return totals
+}
+
+// A Planner chooses actions by simulating the future with
+// a learned model.
+type Planner interface {
+	Plan(d *Dynamics, obs anyvec.Vector) anyvec.Vector
+}
+
+// RandomShooting plans by trying random action sequences
+// and taking the first action of the best one.
+type RandomShooting struct {
+	Horizon    int
+	Candidates int
+}
+
+func (r *RandomShooting) Plan(d *Dynamics, obs anyvec.Vector) anyvec.Vector {
+	seqs := make([][][]float64, r.Candidates)
+	for i := range seqs {
+		seqs[i] = make([][]float64, r.Horizon)
+		for t := range seqs[i] {
+			seqs[i][t] = randomAction(d.Spec)
+		}
+	}
+	rewards := d.ExpectedRewards(obs, seqs)
+	return anyvec64.MakeVectorData(seqs[argmax(rewards)][0])
+}
+
+// CEM plans with the cross-entropy method, repeatedly
+// fitting independent per-step action distributions to the
+// best sampled action sequences.
+//
+// Discrete actions use categorical distributions, and
+// continuous actions use diagonal Gaussians.
+type CEM struct {
+	Horizon    int
+	Candidates int
+	Elites     int
+	Iters      int
+
+	// Smoothing is the fraction of the old distribution
+	// kept after each iteration.
+	Smoothing float64
+}
+
+func (c *CEM) Plan(d *Dynamics, obs anyvec.Vector) anyvec.Vector {
+	spec := d.Spec
+	size := actionSize(spec)
+
+	// For discrete actions, means are probabilities.
+	means := make([][]float64, c.Horizon)
+	stds := make([][]float64, c.Horizon)
+	for t := range means {
+		means[t] = make([]float64, size)
+		stds[t] = make([]float64, size)
+		for a := range means[t] {
+			if spec.Discrete() {
+				means[t][a] = 1 / float64(size)
+			} else {
+				means[t][a] = (spec.ActionLow + spec.ActionHigh) / 2
+				stds[t][a] = (spec.ActionHigh - spec.ActionLow) / 2
+			}
+		}
+	}
+
+	var bestSeq [][]float64
+	bestReward := math.Inf(-1)
+	for iter := 0; iter < c.Iters; iter++ {
+		seqs := make([][][]float64, c.Candidates)
+		for i := range seqs {
+			seqs[i] = make([][]float64, c.Horizon)
+			for t := range seqs[i] {
+				seqs[i][t] = c.sample(spec, means[t], stds[t])
+			}
+		}
+		rewards := d.ExpectedRewards(obs, seqs)
+		indices := make([]int, len(seqs))
+		for i := range indices {
+			indices[i] = i
+		}
+		sort.Slice(indices, func(i, j int) bool {
+			return rewards[indices[i]] > rewards[indices[j]]
+		})
+		if rewards[indices[0]] > bestReward {
+			bestReward = rewards[indices[0]]
+			bestSeq = seqs[indices[0]]
+		}
+
+		for t := range means {
+			for a := range means[t] {
+				var mean, sqMean float64
+				for _, idx := range indices[:c.Elites] {
+					x := seqs[idx][t][a]
+					mean += x
+					sqMean += x * x
+				}
+				mean /= float64(c.Elites)
+				sqMean /= float64(c.Elites)
+				std := math.Sqrt(math.Max(0, sqMean-mean*mean))
+				means[t][a] = c.Smoothing*means[t][a] + (1-c.Smoothing)*mean
+				stds[t][a] = c.Smoothing*stds[t][a] + (1-c.Smoothing)*std
+			}
+		}
+	}
+	return anyvec64.MakeVectorData(bestSeq[0])
+}
+
+func (c *CEM) sample(spec *classic.Spec, mean, std []float64) []float64 {
+	if spec.Discrete() {
+		return oneHot(len(mean), sampleDiscrete(mean))
+	}
+	res := make([]float64, len(mean))
+	for i, m := range mean {
+		res[i] = clip(m+rand.NormFloat64()*std[i], spec.ActionLow, spec.ActionHigh)
+	}
+	return res
+}
+
+func modelInputBatch(states []float64, actions [][]float64) anyvec.Vector {
+	obsSize := len(states) / len(actions)
+	res := make([]float64, 0, len(states)+len(actions)*len(actions[0]))
+	for i, action := range actions {
+		res = append(res, states[i*obsSize:(i+1)*obsSize]...)
+		res = append(res, action...)
+	}
+	return anyvec64.MakeVectorData(res)
+}
+
+func sampleDiscrete(probs []float64) int {
+	x := rand.Float64()
+	for i, p := range probs {
+		x -= p
+		if x < 0 {
+			return i
+		}
+	}
+	return len(probs) - 1
+}
+
+func argmax(x []float64) int {
+	var res int
+	for i, v := range x {
+		if v > x[res] {
+			res = i
+		}
+	}
+	return res
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
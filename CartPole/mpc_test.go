@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/rl-agents/CartPole/classic"
+)
+
+func TestCEMPlan(t *testing.T) {
+	c := anyvec64.DefaultCreator{}
+	for _, name := range []string{"CartPole-v0", "Pendulum-v0"} {
+		spec := classic.Specs[name]
+		inSize := spec.ObsSize + actionSize(spec)
+		dynamics := &Dynamics{
+			Spec:   spec,
+			Next:   NewEnsemble(c, 2, inSize, 8, spec.ObsSize),
+			Reward: anynet.Net{anynet.NewFC(c, inSize, 1)},
+			Done:   anynet.Net{anynet.NewFC(c, inSize, 1)},
+		}
+		planner := &CEM{Horizon: 4, Candidates: 10, Elites: 3, Iters: 1}
+		obs := anyvec64.MakeVectorData(make([]float64, spec.ObsSize))
+		action := planner.Plan(dynamics, obs).Data().([]float64)
+		if len(action) != actionSize(spec) {
+			t.Fatalf("%s: expected action size %d but got %d", name, actionSize(spec),
+				len(action))
+		}
+		for _, x := range action {
+			if spec.Discrete() && x != 0 && x != 1 {
+				t.Errorf("%s: action %v is not one-hot", name, action)
+			} else if !spec.Discrete() && (x < spec.ActionLow || x > spec.ActionHigh) {
+				t.Errorf("%s: action %v is out of bounds", name, action)
+			}
+		}
+	}
+}
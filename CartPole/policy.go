@@ -0,0 +1,143 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/rl-agents/CartPole/classic"
+)
+
+// A Policy is a stochastic policy network.
+//
+// Actions are represented the same way for the policy, the
+// models, and the environment: one-hot vectors for discrete
+// actions, raw vectors for continuous ones.
+type Policy interface {
+	Parameters() []*anydiff.Var
+
+	// Act samples an action for an observation.
+	Act(obs anyvec.Vector) anyvec.Vector
+
+	// LogProbs computes log-probability terms for a batch
+	// of actions.
+	// Each action's terms sum to its log-probability, up to
+	// a constant, and there is one term per action
+	// component.
+	LogProbs(obs, actions anyvec.Vector, n int) anydiff.Res
+}
+
+// NewPolicy creates a linear policy suited to the spec.
+func NewPolicy(c anyvec.Creator, spec *classic.Spec) Policy {
+	if spec.Discrete() {
+		return &SoftmaxPolicy{
+			Net: anynet.Net{
+				anynet.NewFC(c, spec.ObsSize, spec.NumActions),
+				anynet.LogSoftmax,
+			},
+		}
+	}
+	return &GaussianPolicy{
+		Spec:   spec,
+		Mean:   anynet.NewFC(c, spec.ObsSize, spec.ActionSize),
+		LogStd: anynet.NewFCZero(c, spec.ObsSize, spec.ActionSize),
+	}
+}
+
+// SoftmaxPolicy is a Policy for discrete actions.
+type SoftmaxPolicy struct {
+	// Net outputs action log probabilities.
+	Net anynet.Net
+}
+
+func (s *SoftmaxPolicy) Parameters() []*anydiff.Var {
+	return s.Net.Parameters()
+}
+
+func (s *SoftmaxPolicy) Act(obs anyvec.Vector) anyvec.Vector {
+	logProbs := s.Net.Apply(anydiff.NewConst(obs), 1).Output().Data().([]float64)
+	probs := make([]float64, len(logProbs))
+	for i, x := range logProbs {
+		probs[i] = math.Exp(x)
+	}
+	return anyvec64.MakeVectorData(oneHot(len(probs), sampleDiscrete(probs)))
+}
+
+func (s *SoftmaxPolicy) LogProbs(obs, actions anyvec.Vector, n int) anydiff.Res {
+	return anydiff.Mul(s.Net.Apply(anydiff.NewConst(obs), n), anydiff.NewConst(actions))
+}
+
+// GaussianPolicy is a Policy for continuous actions.
+type GaussianPolicy struct {
+	Spec *classic.Spec
+
+	// Mean and LogStd compute the parameters of a diagonal
+	// Gaussian.
+	Mean   anynet.Layer
+	LogStd anynet.Layer
+}
+
+func (g *GaussianPolicy) Parameters() []*anydiff.Var {
+	return anynet.AllParameters(g.Mean, g.LogStd)
+}
+
+// Act samples an action and clips it to the action
+// bounds, so that the models see the same action as the
+// environment.
+func (g *GaussianPolicy) Act(obs anyvec.Vector) anyvec.Vector {
+	in := anydiff.NewConst(obs)
+	mean := g.Mean.Apply(in, 1).Output().Data().([]float64)
+	logStd := g.LogStd.Apply(in, 1).Output().Data().([]float64)
+	res := make([]float64, len(mean))
+	for i, m := range mean {
+		res[i] = clip(m+rand.NormFloat64()*math.Exp(logStd[i]),
+			g.Spec.ActionLow, g.Spec.ActionHigh)
+	}
+	return anyvec64.MakeVectorData(res)
+}
+
+func (g *GaussianPolicy) LogProbs(obs, actions anyvec.Vector, n int) anydiff.Res {
+	c := obs.Creator()
+	in := anydiff.NewConst(obs)
+	logStd := g.LogStd.Apply(in, n)
+	diff := anydiff.Sub(g.Mean.Apply(in, n), anydiff.NewConst(actions))
+	invVar := anydiff.Exp(anydiff.Scale(logStd, c.MakeNumeric(-2)))
+	return anydiff.Sub(
+		anydiff.Scale(anydiff.Mul(anydiff.Square(diff), invVar), c.MakeNumeric(-0.5)),
+		logStd,
+	)
+}
+
+// actionSize returns the size of action vectors.
+func actionSize(spec *classic.Spec) int {
+	if spec.Discrete() {
+		return spec.NumActions
+	}
+	return spec.ActionSize
+}
+
+// randomAction samples a uniformly random action.
+func randomAction(spec *classic.Spec) []float64 {
+	if spec.Discrete() {
+		return oneHot(spec.NumActions, rand.Intn(spec.NumActions))
+	}
+	res := make([]float64, spec.ActionSize)
+	for i := range res {
+		res[i] = spec.ActionLow + rand.Float64()*(spec.ActionHigh-spec.ActionLow)
+	}
+	return res
+}
+
+// oneHot creates a one-hot action vector.
+func oneHot(numActions, action int) []float64 {
+	vec := make([]float64, numActions)
+	vec[action] = 1
+	return vec
+}
+
+func clip(x, min, max float64) float64 {
+	return math.Max(min, math.Min(max, x))
+}
@@ -0,0 +1,45 @@
+package main
+
+import "github.com/unixpickle/anyvec"
+
+// A FeatureMap expands observations into the inputs of the
+// linear policy.
+type FeatureMap interface {
+	NumFeatures(obsSize int) int
+	Features(obs anyvec.Vector) []float64
+}
+
+// FeatureMaps maps feature names to feature maps.
+var FeatureMaps = map[string]FeatureMap{
+	"linear":    LinearFeatures{},
+	"quadratic": QuadraticFeatures{},
+}
+
+// LinearFeatures uses the raw observation.
+type LinearFeatures struct{}
+
+func (l LinearFeatures) NumFeatures(obsSize int) int {
+	return obsSize
+}
+
+func (l LinearFeatures) Features(obs anyvec.Vector) []float64 {
+	return append([]float64{}, obs.Data().([]float64)...)
+}
+
+// QuadraticFeatures appends the squared observation to
+// the observation, so that a linear policy can react to
+// magnitudes as well as signs.
+type QuadraticFeatures struct{}
+
+func (q QuadraticFeatures) NumFeatures(obsSize int) int {
+	return obsSize * 2
+}
+
+func (q QuadraticFeatures) Features(obs anyvec.Vector) []float64 {
+	data := obs.Data().([]float64)
+	res := append([]float64{}, data...)
+	for _, x := range data {
+		res = append(res, x*x)
+	}
+	return res
+}
@@ -0,0 +1,190 @@
+// Use REINFORCE with a moving baseline to train a linear
+// softmax policy.
+//
+// This is a port of gym_test.py, which achieves a good
+// success rate on CartPole-v0 after 500 trials.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/rip"
+	"github.com/unixpickle/rl-agents/CartPole/classic"
+	"github.com/unixpickle/rl-agents/CartPole/gymenv"
+	"github.com/unixpickle/rl-agents/monitor"
+	"github.com/unixpickle/serializer"
+)
+
+const BaseURL = "http://localhost:5000"
+
+func main() {
+	var envName string
+	var native bool
+	var featureName string
+	var batchSize int
+	var iters int
+	var stepSize float64
+	var stepDecay float64
+	var baseline float64
+	var monitorDir string
+	var savePath string
+	var seed int64
+	flag.StringVar(&envName, "env", "CartPole-v0", "environment name")
+	flag.BoolVar(&native, "native", false, "use a Go environment instead of gym")
+	flag.StringVar(&featureName, "features", "quadratic", "observation features (linear, quadratic)")
+	flag.IntVar(&batchSize, "batch", 10, "episodes per update")
+	flag.IntVar(&iters, "iters", 70, "number of updates")
+	flag.Float64Var(&stepSize, "step", 0.02, "initial step size (not saved with the policy, so a resumed run restarts it)")
+	flag.Float64Var(&stepDecay, "decay", 0.95, "step size decay per update")
+	flag.Float64Var(&baseline, "baseline", 10, "initial reward baseline (not saved with the policy)")
+	flag.StringVar(&monitorDir, "monitor", "/tmp/reinforce-monitor", "run directory for the episode log")
+	flag.StringVar(&savePath, "out", "reinforce_policy", "policy checkpoint file")
+	flag.Int64Var(&seed, "seed", time.Now().UnixNano(), "random seed (for -native runs)")
+	flag.Parse()
+
+	features, ok := FeatureMaps[featureName]
+	if !ok {
+		essentials.Die("unknown features:", featureName)
+	}
+
+	log.Println("random seed:", seed)
+	rand.Seed(seed)
+	c := anyvec64.CurrentCreator()
+
+	var env classic.Env
+	var err error
+	if native {
+		env, err = classic.Make(envName, c, nil)
+		must(err)
+	} else {
+		gymEnv, err := gymenv.Make(BaseURL, envName)
+		must(err)
+		defer gymEnv.Close()
+		env = gymEnv
+	}
+	spec := env.Spec()
+	if !spec.Discrete() {
+		essentials.Die("environment has continuous actions:", envName)
+	}
+	monitorEnv, err := monitor.NewEnv(env, monitorDir)
+	must(err)
+	defer monitorEnv.Close()
+	env = classic.WithSpec(monitorEnv, spec)
+
+	policy := loadOrCreatePolicy(c, savePath, features.NumFeatures(spec.ObsSize),
+		spec.NumActions)
+
+	log.Println("Press Ctrl+C to stop.")
+	waiter := rip.NewRIP()
+
+	var numTrials int
+	for i := 0; i < iters && !waiter.Done(); i++ {
+		monitorEnv.SetCheckpoint(fmt.Sprintf("iter-%d", i))
+		stepSize *= stepDecay
+		grad := anydiff.NewGrad(policy.Parameters()...)
+		var totalReward float64
+		for j := 0; j < batchSize; j++ {
+			totalReward += runTrial(env, policy, features, baseline, grad)
+			numTrials++
+		}
+		baseline = totalReward / float64(batchSize)
+		grad.Scale(c.MakeNumeric(stepSize / float64(batchSize)))
+		grad.AddToVars()
+		must(serializer.SaveAny(savePath, policy))
+
+		log.Printf("%d trials: reward=%f step=%f", numTrials, baseline, stepSize)
+	}
+}
+
+// runTrial runs an episode and adds the policy gradient
+// estimate for the episode to grad.
+func runTrial(env classic.Env, policy anynet.Net, features FeatureMap,
+	baseline float64, grad anydiff.Grad) float64 {
+	numActions := env.Spec().NumActions
+	obs, err := env.Reset()
+	must(err)
+
+	var inputs, actions []float64
+	var totalReward float64
+	for {
+		feats := features.Features(obs)
+		in := anydiff.NewConst(anyvec64.MakeVectorData(feats))
+		logProbs := policy.Apply(in, 1).Output().Data().([]float64)
+		action := sampleAction(logProbs)
+		actionVec := make([]float64, numActions)
+		actionVec[action] = 1
+		inputs = append(inputs, feats...)
+		actions = append(actions, actionVec...)
+
+		var reward float64
+		var done bool
+		obs, reward, done, err = env.Step(anyvec64.MakeVectorData(actionVec))
+		must(err)
+		totalReward += reward
+		if done {
+			break
+		}
+	}
+
+	// The gradient of the summed log probabilities of the
+	// actions, scaled by the advantage.
+	n := len(actions) / numActions
+	in := anydiff.NewConst(anyvec64.MakeVectorData(inputs))
+	weights := anyvec64.MakeVectorData(actions)
+	weights.Scale(weights.Creator().MakeNumeric(totalReward - baseline))
+	objective := anydiff.Sum(anydiff.Mul(policy.Apply(in, n), anydiff.NewConst(weights)))
+	objective.Propagate(anyvec64.MakeVectorData([]float64{1}), grad)
+
+	return totalReward
+}
+
+func sampleAction(logProbs []float64) int {
+	x := rand.Float64()
+	for i, logProb := range logProbs {
+		x -= math.Exp(logProb)
+		if x < 0 {
+			return i
+		}
+	}
+	return len(logProbs) - 1
+}
+
+func loadOrCreatePolicy(c anyvec.Creator, path string, numFeatures,
+	numActions int) anynet.Net {
+	var res anynet.Net
+	if err := serializer.LoadAny(path, &res); err == nil {
+		if len(res) == 0 {
+			essentials.Die("saved policy is empty:", path)
+		}
+		fc, ok := res[0].(*anynet.FC)
+		if !ok || fc.InCount != numFeatures || fc.OutCount != numActions {
+			essentials.Die("saved policy does not match the features and actions:", path)
+		}
+		log.Println("Loaded policy from file.")
+		return res
+	} else if !os.IsNotExist(err) {
+		essentials.Die("load policy:", err)
+	}
+	log.Println("Created new policy.")
+	return anynet.Net{
+		anynet.NewFCZero(c, numFeatures, numActions),
+		anynet.LogSoftmax,
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
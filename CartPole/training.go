@@ -0,0 +1,184 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyff"
+	"github.com/unixpickle/anynet/anysgd"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+// A Dataset accumulates samples, holding out a random
+// fraction of them for validation.
+//
+// Samples stay in the same split as the dataset grows, so
+// models which are refit every round never train on their
+// validation data.
+type Dataset struct {
+	ValidFrac float64
+
+	Train anyff.SliceSampleList
+	Valid anyff.SliceSampleList
+}
+
+// Add adds samples to the dataset.
+func (d *Dataset) Add(samples anyff.SliceSampleList) {
+	for _, s := range samples {
+		if rand.Float64() < d.ValidFrac {
+			d.Valid = append(d.Valid, s)
+		} else {
+			d.Train = append(d.Train, s)
+		}
+	}
+}
+
+// FitConfig is a training budget for a model.
+type FitConfig struct {
+	BatchSize int
+	MaxEpochs int
+
+	// Patience is the number of epochs without improvement
+	// in validation loss before training stops.
+	Patience int
+}
+
+// A fitter is a model which can be trained with SGD.
+type fitter interface {
+	Parameters() []*anydiff.Var
+
+	// Step takes an SGD step on a batch.
+	Step(batch anyff.SliceSampleList)
+
+	// Loss computes the mean loss on some samples.
+	Loss(samples anyff.SliceSampleList) float64
+}
+
+// Fit trains a model for at most cfg.MaxEpochs epochs,
+// stopping early once the validation loss stops improving.
+//
+// On return, the model has the parameters which achieved
+// the best validation loss.
+// If there is no validation data, all of the epochs are
+// used.
+//
+// It returns the best validation loss and the number of
+// epochs that were run.
+func Fit(f fitter, train, valid anyff.SliceSampleList, cfg FitConfig) (float64, int) {
+	if len(train) == 0 {
+		return math.NaN(), 0
+	}
+	train = append(anyff.SliceSampleList{}, train...)
+
+	bestLoss := math.Inf(1)
+	var bestParams []anyvec.Vector
+	var badEpochs int
+	var epoch int
+	for epoch < cfg.MaxEpochs {
+		epoch++
+		for i := len(train) - 1; i > 0; i-- {
+			j := rand.Intn(i + 1)
+			train[i], train[j] = train[j], train[i]
+		}
+		for i := 0; i < len(train); i += cfg.BatchSize {
+			f.Step(train[i:minInt(i+cfg.BatchSize, len(train))])
+		}
+
+		if len(valid) == 0 {
+			continue
+		}
+		loss := f.Loss(valid)
+		if loss < bestLoss {
+			bestLoss = loss
+			bestParams = snapshotParams(f.Parameters())
+			badEpochs = 0
+		} else if badEpochs++; badEpochs >= cfg.Patience {
+			break
+		}
+	}
+	if bestParams != nil {
+		for i, p := range f.Parameters() {
+			p.Vector.Set(bestParams[i])
+		}
+	}
+	return bestLoss, epoch
+}
+
+// netFitter fits an anynet.Net to a cost function.
+type netFitter struct {
+	Trainer *anyff.Trainer
+	Adam    anysgd.Adam
+}
+
+func newNetFitter(net anynet.Net, cost anynet.Cost) *netFitter {
+	return &netFitter{
+		Trainer: &anyff.Trainer{
+			Net:     net,
+			Params:  net.Parameters(),
+			Cost:    cost,
+			Average: true,
+		},
+	}
+}
+
+func (n *netFitter) Parameters() []*anydiff.Var {
+	return n.Trainer.Params
+}
+
+func (n *netFitter) Step(batch anyff.SliceSampleList) {
+	b, err := n.Trainer.Fetch(batch)
+	must(err)
+	grad := n.Adam.Transform(n.Trainer.Gradient(b))
+	grad.Scale(-ModelStepSize)
+	grad.AddToVars()
+}
+
+func (n *netFitter) Loss(samples anyff.SliceSampleList) float64 {
+	b, err := n.Trainer.Fetch(samples)
+	must(err)
+	cost := n.Trainer.TotalCost(b.(*anyff.Batch)).Output()
+	return cost.Data().([]float64)[0]
+}
+
+// probFitter fits a ProbModel by maximum likelihood.
+type probFitter struct {
+	Model *ProbModel
+	Adam  anysgd.Adam
+}
+
+func (p *probFitter) Parameters() []*anydiff.Var {
+	return p.Model.Parameters()
+}
+
+func (p *probFitter) Step(batch anyff.SliceSampleList) {
+	in, target := sampleBatch(batch)
+	cost := p.Model.Cost(in, target, len(batch))
+	grad := anydiff.NewGrad(p.Model.Parameters()...)
+	cost.Propagate(anyvec64.MakeVectorData([]float64{1}), grad)
+	grad = p.Adam.Transform(grad)
+	grad.Scale(-ModelStepSize)
+	grad.AddToVars()
+}
+
+func (p *probFitter) Loss(samples anyff.SliceSampleList) float64 {
+	in, target := sampleBatch(samples)
+	return p.Model.Cost(in, target, len(samples)).Output().Data().([]float64)[0]
+}
+
+func snapshotParams(params []*anydiff.Var) []anyvec.Vector {
+	res := make([]anyvec.Vector, len(params))
+	for i, p := range params {
+		res[i] = p.Vector.Copy()
+	}
+	return res
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/gym-socket-api/binding-go"
+	"github.com/unixpickle/rl-agents/CartPole/classic"
+)
+
+// Env is an environment with discrete actions and flat
+// observations.
+type Env interface {
+	NumActions() int
+	ObsSize() int
+
+	// Seed seeds the randomness of the following episodes.
+	// Gym environments ignore it, so only native
+	// environments are reproducible.
+	Seed(seed int64)
+
+	Reset() ([]float64, error)
+	Step(action int) (obs []float64, reward float64, done bool, err error)
+}
+
+// MakeEnv creates an environment, either through a
+// gym-socket-api server or natively in Go.
+func MakeEnv(host, name string, native bool) (Env, error) {
+	if native {
+		gen := rand.New(rand.NewSource(0))
+		env, err := classic.Make(name, anyvec64.CurrentCreator(), gen)
+		if err != nil {
+			return nil, err
+		}
+		if !env.Spec().Discrete() {
+			return nil, fmt.Errorf("make env: %s has continuous actions", name)
+		}
+		return &nativeEnv{Env: env, gen: gen}, nil
+	}
+
+	env, err := gym.Make(host, name)
+	if err != nil {
+		return nil, essentials.AddCtx("make env", err)
+	}
+	actSpace, err := env.ActionSpace()
+	if err != nil {
+		return nil, essentials.AddCtx("make env", err)
+	}
+	if actSpace.Type != "Discrete" {
+		return nil, fmt.Errorf("make env: unsupported action space: %s", actSpace.Type)
+	}
+	obsSpace, err := env.ObservationSpace()
+	if err != nil {
+		return nil, essentials.AddCtx("make env", err)
+	}
+	if obsSpace.Type != "Box" || len(obsSpace.Shape) != 1 {
+		return nil, fmt.Errorf("make env: unsupported observation space: %s %v",
+			obsSpace.Type, obsSpace.Shape)
+	}
+	return &gymEnv{Env: env, numActions: actSpace.N, obsSize: obsSpace.Shape[0]}, nil
+}
+
+type gymEnv struct {
+	gym.Env
+	numActions int
+	obsSize    int
+}
+
+func (g *gymEnv) NumActions() int {
+	return g.numActions
+}
+
+func (g *gymEnv) ObsSize() int {
+	return g.obsSize
+}
+
+func (g *gymEnv) Seed(seed int64) {
+}
+
+func (g *gymEnv) Reset() ([]float64, error) {
+	obs, err := g.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	var res []float64
+	return res, obs.Unmarshal(&res)
+}
+
+func (g *gymEnv) Step(action int) ([]float64, float64, bool, error) {
+	obs, reward, done, _, err := g.Env.Step(action)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	var res []float64
+	return res, reward, done, obs.Unmarshal(&res)
+}
+
+type nativeEnv struct {
+	classic.Env
+	gen *rand.Rand
+}
+
+func (n *nativeEnv) NumActions() int {
+	return n.Spec().NumActions
+}
+
+func (n *nativeEnv) ObsSize() int {
+	return n.Spec().ObsSize
+}
+
+func (n *nativeEnv) Seed(seed int64) {
+	n.gen.Seed(seed)
+}
+
+func (n *nativeEnv) Reset() ([]float64, error) {
+	obs, err := n.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	return vecData(obs), nil
+}
+
+func (n *nativeEnv) Step(action int) ([]float64, float64, bool, error) {
+	oneHot := make([]float64, n.NumActions())
+	oneHot[action] = 1
+	obs, reward, done, err := n.Env.Step(anyvec64.MakeVectorData(oneHot))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return vecData(obs), reward, done, nil
+}
+
+func vecData(v anyvec.Vector) []float64 {
+	return v.Data().([]float64)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// GP evolves a population of trees with genetic
+// programming.
+type GP struct {
+	Space *Space
+
+	// TournamentSize is the number of candidates compared
+	// when selecting each parent.
+	TournamentSize int
+
+	// Elite is the number of top trees copied unchanged
+	// into the next generation.
+	Elite int
+
+	// CrossoverProb is the probability that a child is
+	// produced by subtree crossover, rather than by
+	// copying one parent.
+	CrossoverProb float64
+
+	// MutateProb is the per-node probability of changing a
+	// parameter, threshold, or decision.
+	MutateProb float64
+
+	// GrowProb and PruneProb are the probabilities of
+	// replacing a random leaf with a new branch, or a
+	// random branch with a leaf.
+	GrowProb  float64
+	PruneProb float64
+
+	// MaxDepth limits the depth of the trees.
+	MaxDepth int
+}
+
+// Next produces the next generation from a population and
+// the fitness of each of its trees.
+func (g *GP) Next(pop []PolicyNode, fitness []float64) []PolicyNode {
+	indices := make([]int, len(pop))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return fitness[indices[i]] > fitness[indices[j]]
+	})
+
+	var res []PolicyNode
+	for i := 0; i < g.Elite && i < len(pop); i++ {
+		res = append(res, pop[indices[i]])
+	}
+	for len(res) < len(pop) {
+		var child PolicyNode
+		if rand.Float64() < g.CrossoverProb {
+			child = Crossover(g.tournament(pop, fitness), g.tournament(pop, fitness))
+		} else {
+			child = g.tournament(pop, fitness).Copy()
+		}
+		child.Mutate(g.Space, g.MutateProb)
+		if rand.Float64() < g.GrowProb {
+			child = Grow(g.Space, child)
+		}
+		if rand.Float64() < g.PruneProb {
+			child = Prune(g.Space, child)
+		}
+		res = append(res, Truncate(g.Space, child, g.MaxDepth))
+	}
+	return res
+}
+
+func (g *GP) tournament(pop []PolicyNode, fitness []float64) PolicyNode {
+	best := rand.Intn(len(pop))
+	for i := 1; i < g.TournamentSize; i++ {
+		idx := rand.Intn(len(pop))
+		if fitness[idx] > fitness[best] {
+			best = idx
+		}
+	}
+	return pop[best]
+}
+
+// Crossover replaces a random subtree of a copy of p1 with
+// a copy of a random subtree of p2.
+func Crossover(p1, p2 PolicyNode) PolicyNode {
+	res := p1.Copy()
+	slots := nodeSlots(&res)
+	donors := nodeSlots(&p2)
+	*slots[rand.Intn(len(slots))] = (*donors[rand.Intn(len(donors))]).Copy()
+	return res
+}
+
+// Grow replaces a random leaf with a random branch.
+func Grow(s *Space, p PolicyNode) PolicyNode {
+	var leaves []*PolicyNode
+	for _, slot := range nodeSlots(&p) {
+		if _, ok := (*slot).(*LeafNode); ok {
+			leaves = append(leaves, slot)
+		}
+	}
+	*leaves[rand.Intn(len(leaves))] = NewPolicy(s, 1)
+	return p
+}
+
+// Prune replaces a random branch with a random leaf.
+// Trees without branches are left unchanged.
+func Prune(s *Space, p PolicyNode) PolicyNode {
+	var branches []*PolicyNode
+	for _, slot := range nodeSlots(&p) {
+		if _, ok := (*slot).(*BranchNode); ok {
+			branches = append(branches, slot)
+		}
+	}
+	if len(branches) > 0 {
+		*branches[rand.Intn(len(branches))] = NewPolicy(s, 0)
+	}
+	return p
+}
+
+// Truncate replaces the branches below the maximum depth
+// with leaves.
+func Truncate(s *Space, p PolicyNode, maxDepth int) PolicyNode {
+	branch, ok := p.(*BranchNode)
+	if !ok {
+		return p
+	}
+	if maxDepth == 0 {
+		return NewPolicy(s, 0)
+	}
+	branch.Left = Truncate(s, branch.Left, maxDepth-1)
+	branch.Right = Truncate(s, branch.Right, maxDepth-1)
+	return branch
+}
+
+// nodeSlots returns pointers to every node of a tree,
+// including the root, so that they can be replaced.
+func nodeSlots(root *PolicyNode) []*PolicyNode {
+	res := []*PolicyNode{root}
+	if branch, ok := (*root).(*BranchNode); ok {
+		res = append(res, nodeSlots(&branch.Left)...)
+		res = append(res, nodeSlots(&branch.Right)...)
+	}
+	return res
+}
@@ -1,78 +1,175 @@
-// Slow and crappy hill-climbing algorithm for finding
-// decision tree policy for CartPole.
+// Genetic programming for finding decision tree policies
+// for CartPole and other discrete-action gym environments.
 
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"math"
 	"math/rand"
+	"sort"
 	"sync"
+	"time"
 
-	"github.com/unixpickle/gym-socket-api/binding-go"
+	"github.com/unixpickle/essentials"
 	"github.com/unixpickle/rip"
 )
 
-const (
-	Host        = "127.0.0.1:5001"
-	Population  = 20
-	PolicyDepth = 2
-	MutateProb  = 0.05
-)
-
 func main() {
-	policy := NewPolicy(PolicyDepth)
-	envs := make([]gym.Env, Population)
-	log.Printf("Creating %d environments...", Population)
-	for i := range envs {
-		var err error
-		envs[i], err = gym.Make(Host, "CartPole-v0")
+	var host string
+	var envName string
+	var native bool
+	var warmup int
+	var population int
+	var numEnvs int
+	var episodes int
+	var evalEpisodes int
+	var evalTop int
+	var initDepth int
+	var saveFile string
+	var seed int64
+	gp := &GP{}
+	flag.StringVar(&host, "host", "127.0.0.1:5001", "gym-socket-api host")
+	flag.StringVar(&envName, "env", "CartPole-v0", "gym environment name")
+	flag.BoolVar(&native, "native", false, "use Go environments instead of gym")
+	flag.IntVar(&warmup, "warmup", 20,
+		"random episodes for estimating observation ranges")
+	flag.IntVar(&population, "population", 50, "population size")
+	flag.IntVar(&numEnvs, "envs", 20, "number of environments")
+	flag.IntVar(&episodes, "episodes", 5, "episodes per candidate")
+	flag.IntVar(&evalEpisodes, "eval-episodes", 20,
+		"fixed-seed episodes for re-evaluating the top candidates")
+	flag.IntVar(&evalTop, "eval-top", 3, "top candidates to re-evaluate per generation")
+	flag.IntVar(&initDepth, "depth", 2, "maximum depth of the initial trees")
+	flag.StringVar(&saveFile, "out", "best_tree.json", "file for the best tree")
+	flag.Int64Var(&seed, "seed", time.Now().UnixNano(),
+		"random seed (runs are only reproducible with -native)")
+	flag.IntVar(&gp.TournamentSize, "tournament", 3, "tournament size")
+	flag.IntVar(&gp.Elite, "elite", 2, "number of elite trees")
+	flag.Float64Var(&gp.CrossoverProb, "crossover", 0.7, "crossover probability")
+	flag.Float64Var(&gp.MutateProb, "mutate", 0.05, "per-node mutation probability")
+	flag.Float64Var(&gp.GrowProb, "grow", 0.1, "probability of growing a leaf")
+	flag.Float64Var(&gp.PruneProb, "prune", 0.1, "probability of pruning a branch")
+	flag.IntVar(&gp.MaxDepth, "maxdepth", 6, "maximum tree depth")
+	flag.Parse()
+
+	if evalEpisodes < 1 || evalTop < 1 {
+		essentials.Die("-eval-episodes and -eval-top must be at least 1")
+	}
+	if warmup < 1 {
+		essentials.Die("-warmup must be at least 1")
+	}
+
+	rand.Seed(seed)
+	evalSeeds := randomSeeds(evalEpisodes)
+
+	envs := make(chan Env, numEnvs)
+	log.Printf("Creating %d environments...", numEnvs)
+	for i := 0; i < numEnvs; i++ {
+		env, err := MakeEnv(host, envName, native)
 		must(err)
+		envs <- env
+	}
+
+	log.Println("Estimating observation ranges...")
+	env := <-envs
+	env.Seed(rand.Int63())
+	space, err := EstimateSpace(env, warmup)
+	must(err)
+	envs <- env
+	gp.Space = space
+	log.Printf("actions=%d low=%v high=%v", space.NumActions, space.Low, space.High)
+
+	pop := make([]PolicyNode, population)
+	for i := range pop {
+		pop[i] = NewPolicy(space, rand.Intn(initDepth+1))
 	}
 
 	r := rip.NewRIP()
 
-	var batch int
-	for !r.Done() {
-		policies := make([]PolicyNode, Population)
-		policies[0] = policy
-		for i := 1; i < Population; i++ {
-			policies[i] = policy.Copy()
-			policies[i].Mutate(MutateProb)
+	var best PolicyNode
+	bestReward := math.Inf(-1)
+	for gen := 0; !r.Done(); gen++ {
+		rewards := Rollouts(pop, envs, randomSeeds(episodes))
+
+		// The generation's rewards are noisy, so the top
+		// candidates are compared on the same fixed seeds as
+		// the best tree so far.
+		top := TopPolicies(pop, rewards, evalTop)
+		evalRewards := Rollouts(top, envs, evalSeeds)
+		genBest, genReward := BestPolicy(top, evalRewards)
+		if genReward > bestReward {
+			best, bestReward = genBest.Copy(), genReward
+			must(SavePolicy(saveFile, best))
 		}
-		rewards := Rollouts(policies, envs)
-		var maxReward float64
-		policy, maxReward = BestPolicy(policies, rewards)
-
-		log.Printf("batch=%d max_reward=%f", batch, maxReward)
-		batch++
+		log.Printf("gen=%d max_reward=%f mean_reward=%f eval_reward=%f depth=%d "+
+			"best_reward=%f", gen, maxValue(rewards), mean(rewards), genReward,
+			genBest.Depth(), bestReward)
+		pop = gp.Next(pop, rewards)
 	}
 
-	fmt.Println(policy)
+	fmt.Println(best)
 }
 
-func Rollouts(policies []PolicyNode, envs []gym.Env) []float64 {
-	res := make([]float64, len(envs))
+// Rollouts computes the mean reward of each policy over
+// one episode per seed.
+//
+// Every policy sees the same seeds, and the environments
+// are seeded per episode, so the results do not depend on
+// which environment runs which episode.
+func Rollouts(policies []PolicyNode, envs chan Env, seeds []int64) []float64 {
+	episodeRewards := make([][]float64, len(policies))
 	var wg sync.WaitGroup
 	for i, p := range policies {
-		wg.Add(1)
-		go func(p PolicyNode, e gym.Env, reward *float64) {
-			defer wg.Done()
-			obs, err := e.Reset()
-			must(err)
-			var done bool
-			for !done {
-				var obsVec []float64
-				must(obs.Unmarshal(&obsVec))
-				var rew float64
-				obs, rew, done, _, err = e.Step(p.Decide(obsVec))
+		episodeRewards[i] = make([]float64, len(seeds))
+		for j, seed := range seeds {
+			wg.Add(1)
+			go func(i, j int, seed int64, p PolicyNode) {
+				defer wg.Done()
+				e := <-envs
+				defer func() {
+					envs <- e
+				}()
+				e.Seed(seed)
+				obs, err := e.Reset()
 				must(err)
-				*reward += rew
-			}
-		}(p, envs[i], &res[i])
+				var reward float64
+				var done bool
+				for !done {
+					var rew float64
+					obs, rew, done, err = e.Step(p.Decide(obs))
+					must(err)
+					reward += rew
+				}
+				episodeRewards[i][j] = reward
+			}(i, j, seed, p)
+		}
 	}
 	wg.Wait()
+
+	res := make([]float64, len(policies))
+	for i, rewards := range episodeRewards {
+		res[i] = mean(rewards)
+	}
+	return res
+}
+
+// TopPolicies returns the n policies with the highest
+// rewards, best first.
+func TopPolicies(policies []PolicyNode, rewards []float64, n int) []PolicyNode {
+	indices := make([]int, len(policies))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return rewards[indices[i]] > rewards[indices[j]]
+	})
+	var res []PolicyNode
+	for _, idx := range indices[:minInt(n, len(indices))] {
+		res = append(res, policies[idx])
+	}
 	return res
 }
 
@@ -87,90 +184,35 @@ func BestPolicy(policies []PolicyNode, rewards []float64) (policy PolicyNode, ma
 	return
 }
 
-func NewPolicy(depth int) PolicyNode {
-	if depth == 0 {
-		return &LeafNode{Decision: rand.Intn(2)}
-	}
-	res := &BranchNode{
-		Left:  NewPolicy(depth - 1),
-		Right: NewPolicy(depth - 1),
+func randomSeeds(n int) []int64 {
+	res := make([]int64, n)
+	for i := range res {
+		res[i] = rand.Int63()
 	}
-	res.Mutate(1)
 	return res
 }
 
-type PolicyNode interface {
-	fmt.Stringer
-
-	Mutate(prob float64)
-	Decide(in []float64) int
-	Copy() PolicyNode
-}
-
-type BranchNode struct {
-	Param  int
-	Thresh float64
-	Left   PolicyNode
-	Right  PolicyNode
-}
-
-func (b *BranchNode) String() string {
-	return fmt.Sprintf("if obs[%d] < %f {\n%s\n} else {\n%s\n}",
-		b.Param, b.Thresh, b.Left.String(), b.Right.String())
-}
-
-func (b *BranchNode) Mutate(prob float64) {
-	if rand.Float64() < prob {
-		b.Param = rand.Intn(4)
-	}
-	if rand.Float64() < prob {
-		b.Thresh = randomThreshold(b.Param)
-	}
-	b.Left.Mutate(prob)
-	b.Right.Mutate(prob)
-}
-
-func (b *BranchNode) Decide(in []float64) int {
-	if in[b.Param] > b.Thresh {
-		return b.Right.Decide(in)
-	} else {
-		return b.Left.Decide(in)
+func maxValue(x []float64) float64 {
+	res := math.Inf(-1)
+	for _, v := range x {
+		res = math.Max(res, v)
 	}
+	return res
 }
 
-func (b *BranchNode) Copy() PolicyNode {
-	return &BranchNode{
-		Param:  b.Param,
-		Thresh: b.Thresh,
-		Left:   b.Left.Copy(),
-		Right:  b.Right.Copy(),
+func minInt(x, y int) int {
+	if x < y {
+		return x
 	}
+	return y
 }
 
-type LeafNode struct {
-	Decision int
-}
-
-func (l *LeafNode) String() string {
-	return fmt.Sprintf("return %d", l.Decision)
-}
-
-func (l *LeafNode) Mutate(prob float64) {
-	if rand.Float64() < prob {
-		l.Decision = rand.Intn(2)
+func mean(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v
 	}
-}
-
-func (l *LeafNode) Decide(in []float64) int {
-	return l.Decision
-}
-
-func (l *LeafNode) Copy() PolicyNode {
-	return &LeafNode{Decision: l.Decision}
-}
-
-func randomThreshold(param int) float64 {
-	return rand.NormFloat64() * 10
+	return sum / float64(len(x))
 }
 
 func must(err error) {
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+)
+
+func NewPolicy(s *Space, depth int) PolicyNode {
+	if depth == 0 {
+		return &LeafNode{Decision: s.RandomAction()}
+	}
+	res := &BranchNode{
+		Left:  NewPolicy(s, depth-1),
+		Right: NewPolicy(s, depth-1),
+	}
+	res.Mutate(s, 1)
+	return res
+}
+
+type PolicyNode interface {
+	fmt.Stringer
+
+	Mutate(s *Space, prob float64)
+	Decide(in []float64) int
+	Copy() PolicyNode
+
+	// Depth returns the number of branches on the longest
+	// path to a leaf.
+	Depth() int
+}
+
+type BranchNode struct {
+	Param  int
+	Thresh float64
+	Left   PolicyNode
+	Right  PolicyNode
+}
+
+func (b *BranchNode) String() string {
+	return fmt.Sprintf("if obs[%d] < %f {\n%s\n} else {\n%s\n}",
+		b.Param, b.Thresh, b.Left.String(), b.Right.String())
+}
+
+func (b *BranchNode) Mutate(s *Space, prob float64) {
+	if rand.Float64() < prob {
+		b.Param = s.RandomFeature()
+		b.Thresh = s.RandomThreshold(b.Param)
+	} else if rand.Float64() < prob {
+		b.Thresh = s.RandomThreshold(b.Param)
+	}
+	b.Left.Mutate(s, prob)
+	b.Right.Mutate(s, prob)
+}
+
+func (b *BranchNode) Decide(in []float64) int {
+	if in[b.Param] > b.Thresh {
+		return b.Right.Decide(in)
+	} else {
+		return b.Left.Decide(in)
+	}
+}
+
+func (b *BranchNode) Copy() PolicyNode {
+	return &BranchNode{
+		Param:  b.Param,
+		Thresh: b.Thresh,
+		Left:   b.Left.Copy(),
+		Right:  b.Right.Copy(),
+	}
+}
+
+func (b *BranchNode) Depth() int {
+	left, right := b.Left.Depth(), b.Right.Depth()
+	if left > right {
+		return left + 1
+	}
+	return right + 1
+}
+
+type LeafNode struct {
+	Decision int
+}
+
+func (l *LeafNode) String() string {
+	return fmt.Sprintf("return %d", l.Decision)
+}
+
+func (l *LeafNode) Mutate(s *Space, prob float64) {
+	if rand.Float64() < prob {
+		l.Decision = s.RandomAction()
+	}
+}
+
+func (l *LeafNode) Decide(in []float64) int {
+	return l.Decision
+}
+
+func (l *LeafNode) Copy() PolicyNode {
+	return &LeafNode{Decision: l.Decision}
+}
+
+func (l *LeafNode) Depth() int {
+	return 0
+}
+
+// savedNode is the JSON representation of a PolicyNode.
+type savedNode struct {
+	Param  int        `json:",omitempty"`
+	Thresh float64    `json:",omitempty"`
+	Left   *savedNode `json:",omitempty"`
+	Right  *savedNode `json:",omitempty"`
+
+	Decision *int `json:",omitempty"`
+}
+
+// SavePolicy saves a policy as JSON.
+func SavePolicy(path string, policy PolicyNode) error {
+	data, err := json.MarshalIndent(encodePolicy(policy), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadPolicy loads a policy saved with SavePolicy.
+func LoadPolicy(path string) (PolicyNode, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var saved savedNode
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	return decodePolicy(&saved)
+}
+
+func encodePolicy(p PolicyNode) *savedNode {
+	switch p := p.(type) {
+	case *BranchNode:
+		return &savedNode{
+			Param:  p.Param,
+			Thresh: p.Thresh,
+			Left:   encodePolicy(p.Left),
+			Right:  encodePolicy(p.Right),
+		}
+	case *LeafNode:
+		decision := p.Decision
+		return &savedNode{Decision: &decision}
+	default:
+		panic(fmt.Sprintf("unknown node type: %T", p))
+	}
+}
+
+func decodePolicy(s *savedNode) (PolicyNode, error) {
+	if s.Decision != nil {
+		return &LeafNode{Decision: *s.Decision}, nil
+	}
+	if s.Left == nil || s.Right == nil {
+		return nil, errors.New("branch is missing a child")
+	}
+	left, err := decodePolicy(s.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := decodePolicy(s.Right)
+	if err != nil {
+		return nil, err
+	}
+	return &BranchNode{Param: s.Param, Thresh: s.Thresh, Left: left, Right: right}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A Space describes the observations and actions which a
+// policy works with.
+type Space struct {
+	NumActions int
+
+	// Low and High give the range of thresholds for each
+	// observation feature.
+	Low  []float64
+	High []float64
+}
+
+// NumFeatures returns the size of the observations.
+func (s *Space) NumFeatures() int {
+	return len(s.Low)
+}
+
+// RandomFeature samples a feature index.
+func (s *Space) RandomFeature() int {
+	return rand.Intn(s.NumFeatures())
+}
+
+// RandomThreshold samples a threshold for a feature.
+func (s *Space) RandomThreshold(feature int) float64 {
+	return s.Low[feature] + rand.Float64()*(s.High[feature]-s.Low[feature])
+}
+
+// RandomAction samples an action.
+func (s *Space) RandomAction() int {
+	return rand.Intn(s.NumActions)
+}
+
+// EstimateSpace creates a Space for an environment.
+//
+// Observation spaces often have infinite or very loose
+// bounds, so the threshold ranges are estimated from the
+// observations seen while taking random actions.
+// At least one episode is required.
+func EstimateSpace(env Env, episodes int) (*Space, error) {
+	if episodes < 1 {
+		return nil, errors.New("estimate space: no warmup episodes")
+	}
+	space := &Space{
+		NumActions: env.NumActions(),
+		Low:        make([]float64, env.ObsSize()),
+		High:       make([]float64, env.ObsSize()),
+	}
+	for i := range space.Low {
+		space.Low[i] = math.Inf(1)
+		space.High[i] = math.Inf(-1)
+	}
+	for i := 0; i < episodes; i++ {
+		obs, err := env.Reset()
+		if err != nil {
+			return nil, essentials.AddCtx("estimate space", err)
+		}
+		var done bool
+		for !done {
+			if len(obs) != space.NumFeatures() {
+				return nil, errors.New("estimate space: unexpected observation size")
+			}
+			for j, x := range obs {
+				space.Low[j] = math.Min(space.Low[j], x)
+				space.High[j] = math.Max(space.High[j], x)
+			}
+			obs, _, done, err = env.Step(space.RandomAction())
+			if err != nil {
+				return nil, essentials.AddCtx("estimate space", err)
+			}
+		}
+	}
+	return space, nil
+}
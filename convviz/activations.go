@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/rl-agents/treepolicy"
+)
+
+// An ActivationMap is the output of one layer, laid out
+// like the input of a conv layer.
+type ActivationMap struct {
+	Name   string
+	Type   string
+	Width  int
+	Height int
+	Depth  int
+	Values []float64
+}
+
+// Activations runs a stack on one input and records the
+// spatial activations of every conv network in it.
+//
+// The stack is run on the input twice, so that recurrent
+// blocks (like the Markov frame history) see the input as
+// a still scene rather than the first frame of an episode.
+func Activations(stack anyrnn.Stack, in anyvec.Vector) []*ActivationMap {
+	states := make([]anyrnn.State, len(stack))
+	x := in
+	for i, block := range stack {
+		res := block.Step(block.Start(1), x)
+		states[i] = res.State()
+		x = res.Output()
+	}
+
+	var res []*ActivationMap
+	x = in
+	for i, block := range stack {
+		name := fmt.Sprintf("policy.%d", i)
+		if layerBlock, ok := block.(*anyrnn.LayerBlock); ok {
+			if net, ok := layerBlock.Layer.(anynet.Net); ok {
+				var maps []*ActivationMap
+				maps, x = netActivations(name, net, x)
+				res = append(res, maps...)
+				continue
+			}
+		}
+		x = block.Step(states[i], x).Output()
+	}
+	return res
+}
+
+// netActivations applies the layers of a network one at a
+// time, recording every output which still has the shape
+// of the last conv layer's output.
+func netActivations(name string, net anynet.Net, in anyvec.Vector) ([]*ActivationMap,
+	anyvec.Vector) {
+	var width, height, depth int
+	for _, layer := range net {
+		if conv, ok := layer.(*anyconv.Conv); ok {
+			width, height, depth = conv.InputWidth, conv.InputHeight, conv.InputDepth
+			break
+		}
+	}
+
+	var res []*ActivationMap
+	addMap := func(name, layerType string, out anyvec.Vector) bool {
+		if depth == 0 || out.Len() != width*height*depth {
+			return false
+		}
+		res = append(res, &ActivationMap{
+			Name:   name,
+			Type:   layerType,
+			Width:  width,
+			Height: height,
+			Depth:  depth,
+			Values: treepolicy.VecFloats(out),
+		})
+		return true
+	}
+	addMap(name+".input", "input", in)
+
+	cur := anydiff.NewConst(in)
+	for i, layer := range net {
+		cur = layer.Apply(cur, 1)
+		if conv, ok := layer.(*anyconv.Conv); ok {
+			width, height, depth = conv.OutputWidth(), conv.OutputHeight(),
+				conv.OutputDepth()
+		}
+		if !addMap(fmt.Sprintf("%s.%d", name, i), fmt.Sprintf("%T", layer), cur.Output()) {
+			// Past the conv layers (e.g. in the FC layers).
+			depth = 0
+		}
+	}
+	return res, cur.Output()
+}
+
+// ConvLayer is a named conv layer in a stack.
+type ConvLayer struct {
+	Name string
+	Conv *anyconv.Conv
+}
+
+// ConvLayers finds every conv layer in a model, named the
+// same way as the activation maps.
+func ConvLayers(name string, obj interface{}) []*ConvLayer {
+	var res []*ConvLayer
+	switch obj := obj.(type) {
+	case anyrnn.Stack:
+		for i, block := range obj {
+			res = append(res, ConvLayers(fmt.Sprintf("%s.%d", name, i), block)...)
+		}
+	case *anyrnn.LayerBlock:
+		res = ConvLayers(name, obj.Layer)
+	case anynet.Net:
+		for i, layer := range obj {
+			res = append(res, ConvLayers(fmt.Sprintf("%s.%d", name, i), layer)...)
+		}
+	case *anyconv.Conv:
+		res = []*ConvLayer{{Name: name, Conv: obj}}
+	}
+	return res
+}
@@ -0,0 +1,109 @@
+// Command convviz draws the filters of a saved conv policy
+// and, given a frame, the activation maps of its layers.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/muniverse"
+	"github.com/unixpickle/rl-agents/treepolicy"
+	"github.com/unixpickle/serializer"
+)
+
+func main() {
+	var policyFile string
+	var outDir string
+	var specName string
+	var stride int
+	var framePath string
+	var filterScale int
+	var actScale int
+	var actCols int
+	flag.StringVar(&policyFile, "file", "trained_policy", "saved anyrnn.Stack policy")
+	flag.StringVar(&outDir, "out", "convviz", "output directory")
+	flag.StringVar(&specName, "spec", "DontCrash-v0", "muniverse environment (for the frame size)")
+	flag.IntVar(&stride, "stride", 4, "downsampling stride of the preprocessor")
+	flag.StringVar(&framePath, "frame", "", "PNG frame to dump activations for")
+	flag.IntVar(&filterScale, "filter-scale", 8, "size of each filter weight in pixels")
+	flag.IntVar(&actScale, "act-scale", 2, "size of each activation in pixels")
+	flag.IntVar(&actCols, "act-cols", 8, "activation maps per row")
+	flag.Parse()
+
+	var policy anyrnn.Stack
+	if err := serializer.LoadAny(policyFile, &policy); err != nil {
+		essentials.Die(err)
+	}
+
+	convs := ConvLayers("policy", policy)
+	if len(convs) == 0 {
+		essentials.Die("no conv layers in", policyFile)
+	}
+	for _, layer := range convs {
+		c := layer.Conv
+		log.Printf("%s: in=%dx%dx%d filters=%dx%dx%d stride=%dx%d", layer.Name,
+			c.InputWidth, c.InputHeight, c.InputDepth,
+			c.FilterCount, c.FilterWidth, c.FilterHeight, c.StrideX, c.StrideY)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		essentials.Die(err)
+	}
+
+	// Each row is a filter and each column is an input
+	// channel (i.e. a frame of the Markov history).
+	first := convs[0].Conv
+	filterImg := drawTiles(filterTiles(first), first.FilterWidth, first.FilterHeight,
+		first.InputDepth, filterScale)
+	filterPath := filepath.Join(outDir, "filters.png")
+	if err := treepolicy.SavePNG(filterPath, filterImg); err != nil {
+		essentials.Die(err)
+	}
+	log.Println("Saved", filterPath)
+
+	if framePath == "" {
+		return
+	}
+	spec := muniverse.SpecForName(specName)
+	if spec == nil {
+		essentials.Die("environment not found:", specName)
+	}
+	grid := &treepolicy.Grid{
+		FrameWidth:  spec.Width,
+		FrameHeight: spec.Height,
+		Stride:      stride,
+	}
+	frame, err := treepolicy.LoadFrame(framePath)
+	if err != nil {
+		essentials.Die(err)
+	}
+	rgb, err := grid.ImageRGB(frame)
+	if err != nil {
+		essentials.Die(err)
+	}
+	features := grid.Features(rgb)
+	creator := anynet.AllParameters(policy)[0].Vector.Creator()
+	in := creator.MakeVectorData(creator.MakeNumericList(features))
+
+	actDir := filepath.Join(outDir, "activations")
+	if err := os.MkdirAll(actDir, 0755); err != nil {
+		essentials.Die(err)
+	}
+	for _, m := range Activations(policy, in) {
+		tiles := channelTiles(m.Values, m.Width, m.Height, m.Depth)
+		cols := actCols
+		if m.Depth < cols {
+			cols = m.Depth
+		}
+		path := filepath.Join(actDir, m.Name+".png")
+		if err := treepolicy.SavePNG(path, drawTiles(tiles, m.Width, m.Height, cols, actScale)); err != nil {
+			essentials.Die(err)
+		}
+		log.Printf("Saved %s (%s, %dx%dx%d)", path, m.Type, m.Width, m.Height, m.Depth)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/rl-agents/treepolicy"
+)
+
+var gridColor = color.RGBA{R: 0x30, G: 0x30, B: 0x90, A: 0xff}
+
+// filterTiles produces one tile per (filter, input channel)
+// pair, ordered so that each row of a grid with InputDepth
+// columns is one filter.
+//
+// Values are scaled to [0, 1] per filter, with zero at 0.5,
+// so that the channels of a filter can be compared.
+func filterTiles(c *anyconv.Conv) [][]float64 {
+	data := treepolicy.VecFloats(c.Filters.Vector)
+	tileSize := c.FilterWidth * c.FilterHeight
+	filterSize := tileSize * c.InputDepth
+
+	var res [][]float64
+	for i := 0; i < c.FilterCount; i++ {
+		filter := data[i*filterSize : (i+1)*filterSize]
+		var maxAbs float64
+		for _, x := range filter {
+			maxAbs = math.Max(maxAbs, math.Abs(x))
+		}
+		for d := 0; d < c.InputDepth; d++ {
+			tile := make([]float64, tileSize)
+			for j := range tile {
+				tile[j] = 0.5
+				if maxAbs > 0 {
+					tile[j] += 0.5 * filter[j*c.InputDepth+d] / maxAbs
+				}
+			}
+			res = append(res, tile)
+		}
+	}
+	return res
+}
+
+// channelTiles splits a width x height x depth tensor into
+// one tile per channel, scaling each tile to [0, 1].
+func channelTiles(data []float64, width, height, depth int) [][]float64 {
+	var res [][]float64
+	for d := 0; d < depth; d++ {
+		tile := make([]float64, width*height)
+		min, max := math.Inf(1), math.Inf(-1)
+		for i := range tile {
+			tile[i] = data[i*depth+d]
+			min = math.Min(min, tile[i])
+			max = math.Max(max, tile[i])
+		}
+		for i, x := range tile {
+			if max > min {
+				tile[i] = (x - min) / (max - min)
+			} else {
+				tile[i] = 0
+			}
+		}
+		res = append(res, tile)
+	}
+	return res
+}
+
+// drawTiles lays out grayscale tiles in a grid with the
+// given number of columns.
+// Each tile pixel becomes a scale x scale block, and tiles
+// are separated by one pixel of gridColor.
+func drawTiles(tiles [][]float64, width, height, cols, scale int) *image.RGBA {
+	rows := (len(tiles) + cols - 1) / cols
+	tileWidth, tileHeight := width*scale+1, height*scale+1
+	res := image.NewRGBA(image.Rect(0, 0, cols*tileWidth+1, rows*tileHeight+1))
+	draw.Draw(res, res.Bounds(), image.NewUniform(gridColor), image.ZP, draw.Src)
+
+	for i, tile := range tiles {
+		left := (i%cols)*tileWidth + 1
+		top := (i/cols)*tileHeight + 1
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				value := math.Max(0, math.Min(1, tile[y*width+x]))
+				c := color.Gray{Y: uint8(value*255 + 0.5)}
+				rect := image.Rect(left+x*scale, top+y*scale, left+(x+1)*scale,
+					top+(y+1)*scale)
+				draw.Draw(res, rect, image.NewUniform(c), image.ZP, draw.Src)
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyrl/anyes"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// A Behavior characterizes what a policy did during a
+// rollout, independently of the reward it got.
+type Behavior []float64
+
+// A BehaviorFunc accumulates a Behavior over an episode.
+type BehaviorFunc interface {
+	Reset(obs anyvec.Vector)
+	Step(action, obs anyvec.Vector, reward float64)
+	Behavior() Behavior
+}
+
+// NewBehaviorFunc creates a BehaviorFunc by name.
+//
+// Supported names are "actions" (a histogram of clicks
+// over time) and "final" (a coarse view of the final
+// frame).
+// The empty name yields a nil BehaviorFunc.
+func NewBehaviorFunc(name string) (BehaviorFunc, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "actions":
+		return &ActionHistogram{Bins: 10}, nil
+	case "final":
+		return &FinalState{CellSize: 20}, nil
+	default:
+		return nil, errors.New("unknown behavior: " + name)
+	}
+}
+
+// ActionHistogram characterizes an episode by how often
+// the agent clicked in each segment of the episode.
+//
+// The final feature is the fraction of MaxRolloutSteps
+// that the episode lasted.
+type ActionHistogram struct {
+	Bins int
+
+	clicks []float64
+	steps  int
+}
+
+func (a *ActionHistogram) Reset(obs anyvec.Vector) {
+	a.clicks = make([]float64, a.Bins)
+	a.steps = 0
+}
+
+func (a *ActionHistogram) Step(action, obs anyvec.Vector, reward float64) {
+	if a.steps < MaxRolloutSteps && vecFloats(action)[0] > 0 {
+		a.clicks[a.steps*a.Bins/MaxRolloutSteps]++
+	}
+	a.steps++
+}
+
+func (a *ActionHistogram) Behavior() Behavior {
+	binSize := float64(MaxRolloutSteps) / float64(a.Bins)
+	res := make(Behavior, a.Bins+1)
+	for i, c := range a.clicks {
+		res[i] = c / binSize
+	}
+	res[a.Bins] = float64(a.steps) / MaxRolloutSteps
+	return res
+}
+
+// FinalState characterizes an episode by its last frame,
+// average-pooled into square cells and scaled to [0, 1].
+type FinalState struct {
+	CellSize int
+
+	last anyvec.Vector
+}
+
+func (f *FinalState) Reset(obs anyvec.Vector) {
+	f.last = obs
+}
+
+func (f *FinalState) Step(action, obs anyvec.Vector, reward float64) {
+	f.last = obs
+}
+
+func (f *FinalState) Behavior() Behavior {
+	const width = FrameWidth / 4
+	const height = FrameHeight / 4
+	cols := width / f.CellSize
+	rows := height / f.CellSize
+	res := make(Behavior, cols*rows)
+	pixels := vecFloats(f.last)
+	for y := 0; y < rows*f.CellSize; y++ {
+		for x := 0; x < cols*f.CellSize; x++ {
+			res[(y/f.CellSize)*cols+x/f.CellSize] += pixels[y*width+x]
+		}
+	}
+	for i := range res {
+		res[i] /= float64(f.CellSize*f.CellSize) * 255
+	}
+	return res
+}
+
+// BehaviorEnv feeds an environment's steps to a
+// BehaviorFunc.
+type BehaviorEnv struct {
+	Env  anyrl.Env
+	Func BehaviorFunc
+}
+
+func (b *BehaviorEnv) Reset() (observation anyvec.Vector, err error) {
+	observation, err = b.Env.Reset()
+	if err == nil {
+		b.Func.Reset(observation)
+	}
+	return
+}
+
+func (b *BehaviorEnv) Step(action anyvec.Vector) (observation anyvec.Vector,
+	reward float64, done bool, err error) {
+	observation, reward, done, err = b.Env.Step(action)
+	if err == nil {
+		b.Func.Step(action, observation, reward)
+	}
+	return
+}
+
+// A RolloutKey identifies a rollout within an update.
+type RolloutKey struct {
+	Seed  int64
+	Scale float64
+}
+
+// A BehaviorSink receives the behaviors of rollouts.
+type BehaviorSink interface {
+	ReportBehavior(key RolloutKey, b Behavior) error
+}
+
+// BehaviorSlave is a slave which reports the behavior of
+// every rollout it runs to a BehaviorSink.
+type BehaviorSlave struct {
+	anyes.Slave
+
+	Env  *BehaviorEnv
+	Sink BehaviorSink
+}
+
+// Run runs the rollout and reports its behavior.
+//
+// Failing to report a behavior is not fatal, since the
+// master will simply leave the rollout out of the update.
+func (b *BehaviorSlave) Run(stop *anyes.StopConds, scale float64,
+	seed int64) (*anyes.Rollout, error) {
+	r, err := b.Slave.Run(stop, scale, seed)
+	if err != nil {
+		return nil, err
+	}
+	key := RolloutKey{Seed: seed, Scale: scale}
+	if err := b.Sink.ReportBehavior(key, b.Env.Func.Behavior()); err != nil {
+		log.Println(err)
+	}
+	return r, nil
+}
+
+// BehaviorStore collects behaviors on the master.
+type BehaviorStore struct {
+	lock      sync.Mutex
+	cond      *sync.Cond
+	behaviors map[RolloutKey]Behavior
+}
+
+// NewBehaviorStore creates an empty BehaviorStore.
+func NewBehaviorStore() *BehaviorStore {
+	res := &BehaviorStore{behaviors: map[RolloutKey]Behavior{}}
+	res.cond = sync.NewCond(&res.lock)
+	return res
+}
+
+// ReportBehavior adds a behavior to the store.
+func (b *BehaviorStore) ReportBehavior(key RolloutKey, beh Behavior) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.behaviors[key] = beh
+	b.cond.Broadcast()
+	return nil
+}
+
+// Take waits for the behaviors of the given rollouts and
+// then empties the store.
+//
+// Since behaviors are reported separately from rollouts,
+// some may arrive after the rollouts themselves.
+// Behaviors which do not arrive before the timeout are
+// nil in the result.
+func (b *BehaviorStore) Take(rollouts []*anyes.Rollout, timeout time.Duration) []Behavior {
+	timer := time.AfterFunc(timeout, func() {
+		b.lock.Lock()
+		b.cond.Broadcast()
+		b.lock.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for !b.hasAll(rollouts) && time.Now().Before(deadline) {
+		b.cond.Wait()
+	}
+	res := make([]Behavior, len(rollouts))
+	for i, r := range rollouts {
+		res[i] = b.behaviors[RolloutKey{Seed: r.Seed, Scale: r.Scale}]
+	}
+	b.behaviors = map[RolloutKey]Behavior{}
+	return res
+}
+
+// Serve reads behaviors from a behavior connection until
+// it is closed.
+func (b *BehaviorStore) Serve(conn net.Conn) error {
+	defer conn.Close()
+	for {
+		var msg behaviorMsg
+		if err := readFrame(conn, &msg); err != nil {
+			return err
+		}
+		b.ReportBehavior(RolloutKey{Seed: msg.Seed, Scale: msg.Scale}, msg.Behavior)
+	}
+}
+
+func (b *BehaviorStore) hasAll(rollouts []*anyes.Rollout) bool {
+	for _, r := range rollouts {
+		if _, ok := b.behaviors[RolloutKey{Seed: r.Seed, Scale: r.Scale}]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// BehaviorClient reports behaviors to a remote master.
+//
+// It connects lazily, and reconnects after errors.
+type BehaviorClient struct {
+	Addr      string
+	Transport *TransportFlags
+	Handshake *Handshake
+
+	lock sync.Mutex
+	conn net.Conn
+}
+
+// ReportBehavior sends a behavior to the master.
+func (b *BehaviorClient) ReportBehavior(key RolloutKey, beh Behavior) error {
+	if err := b.report(key, beh); err != nil {
+		return essentials.AddCtx("report behavior", err)
+	}
+	return nil
+}
+
+func (b *BehaviorClient) report(key RolloutKey, beh Behavior) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.conn == nil {
+		conn, err := b.Transport.Dial(b.Addr)
+		if err != nil {
+			return err
+		}
+		if err := b.Handshake.Client(conn); err != nil {
+			conn.Close()
+			return err
+		}
+		b.conn = conn
+	}
+	msg := &behaviorMsg{Seed: key.Seed, Scale: key.Scale, Behavior: beh}
+	if err := writeFrame(b.conn, msg); err != nil {
+		b.conn.Close()
+		b.conn = nil
+		return err
+	}
+	return nil
+}
+
+type behaviorMsg struct {
+	Seed     int64
+	Scale    float64
+	Behavior Behavior
+}
+
+func vecFloats(v anyvec.Vector) []float64 {
+	switch data := v.Data().(type) {
+	case []float32:
+		res := make([]float64, len(data))
+		for i, x := range data {
+			res[i] = float64(x)
+		}
+		return res
+	case []float64:
+		return data
+	default:
+		panic("unsupported numeric type")
+	}
+}
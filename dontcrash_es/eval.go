@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+
+	"github.com/unixpickle/anyrl/anyes"
+)
+
+// EvaluateCenter runs n rollouts of the unperturbed
+// policy, spread across the ready slaves.
+//
+// It must not be called while the master is gathering
+// rollouts or updating the slaves.
+//
+// Slaves which fail are disconnected, and their rollouts
+// are left out of the result.
+func (s *Session) EvaluateCenter(n int) ([]*anyes.Rollout, error) {
+	slaves := s.Slaves.Ready()
+	if len(slaves) == 0 {
+		return nil, errors.New("evaluate center: no slaves")
+	}
+
+	jobs := make(chan int64, n)
+	for i := 0; i < n; i++ {
+		jobs <- rand.Int63()
+	}
+	close(jobs)
+
+	var lock sync.Mutex
+	var res []*anyes.Rollout
+	var wg sync.WaitGroup
+	for _, slave := range slaves {
+		wg.Add(1)
+		go func(slave *TrackedSlave) {
+			defer wg.Done()
+			for seed := range jobs {
+				stopCond := &anyes.StopConds{MaxSteps: MaxRolloutSteps}
+				rollout, err := slave.Run(stopCond, 0, seed)
+				if err != nil {
+					log.Println(slave, "disconnect:", err)
+					slave.Close()
+					return
+				}
+				lock.Lock()
+				res = append(res, rollout)
+				lock.Unlock()
+			}
+		}(slave)
+	}
+	wg.Wait()
+
+	if len(res) == 0 {
+		return nil, errors.New("evaluate center: all rollouts failed")
+	}
+	return res, nil
+}
+
+// evaluate evaluates the unperturbed policy and saves it
+// if it is the best one so far.
+func (s *Session) evaluate() {
+	log.Println("Evaluating unperturbed policy...")
+	rollouts, err := s.EvaluateCenter(s.Flags.EvalRollouts)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	stats := ComputeRewardStats(rollouts)
+	s.Status.AddCenter(stats)
+	log.Printf("update %d: center_mean=%f center_stddev=%f (n=%d)", s.State.Iteration,
+		stats.Mean, stats.Stddev, stats.Count)
+
+	best := s.State.BestCenterReward
+	if best == nil || stats.Mean > *best {
+		must(SavePolicy(s.Flags.BestFile, s.Policy))
+		s.State.BestCenterReward = &stats.Mean
+		log.Printf("saved new best policy (center_mean=%f)", stats.Mean)
+	}
+}
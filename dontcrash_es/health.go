@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/anyrl/anyes"
+)
+
+// SlaveTracker keeps track of the slaves connected to a
+// master, along with their health statistics.
+//
+// Disconnected slaves are kept, so that their errors and
+// timeouts show up in the status.
+// When a slave reconnects from the same host, it takes
+// over the ID and the error counts of a disconnected
+// slave from that host.
+type SlaveTracker struct {
+	// Timeout is the rollout deadline given to each
+	// slave.
+	// If it is 0, rollouts never time out.
+	Timeout time.Duration
+
+	lock   sync.Mutex
+	nextID int
+	slaves map[int]*TrackedSlave
+}
+
+// Track wraps a slave and adds it to the tracker.
+//
+// The closer is used to forcibly disconnect the slave,
+// e.g. when a rollout times out.
+// It may be nil.
+func (s *SlaveTracker) Track(slave anyes.Slave, addr string,
+	closer io.Closer) *TrackedSlave {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.slaves == nil {
+		s.slaves = map[int]*TrackedSlave{}
+	}
+	res := &TrackedSlave{
+		Slave:     slave,
+		ID:        s.nextID,
+		Addr:      addr,
+		Timeout:   s.Timeout,
+		closer:    closer,
+		joined:    time.Now(),
+		connected: true,
+	}
+	if old := s.disconnectedFrom(slaveHost(addr)); old != nil {
+		old.lock.Lock()
+		res.ID = old.ID
+		res.reconnects = old.reconnects + 1
+		res.errors = old.errors
+		res.timeouts = old.timeouts
+		res.lastError = old.lastError
+		old.lock.Unlock()
+	} else {
+		s.nextID++
+	}
+	s.slaves[res.ID] = res
+	return res
+}
+
+// Slaves returns the tracked slaves, including the
+// disconnected ones, sorted by ID.
+func (s *SlaveTracker) Slaves() []*TrackedSlave {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var res []*TrackedSlave
+	for _, slave := range s.slaves {
+		res = append(res, slave)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+// Ready returns the connected slaves which have been
+// initialized by the master.
+func (s *SlaveTracker) Ready() []*TrackedSlave {
+	var res []*TrackedSlave
+	for _, slave := range s.Slaves() {
+		slave.lock.Lock()
+		if slave.ready && slave.connected {
+			res = append(res, slave)
+		}
+		slave.lock.Unlock()
+	}
+	return res
+}
+
+// Status returns a status snapshot for every tracked
+// slave.
+func (s *SlaveTracker) Status() []*SlaveStatus {
+	var res []*SlaveStatus
+	for _, slave := range s.Slaves() {
+		res = append(res, slave.Status())
+	}
+	return res
+}
+
+// disconnectedFrom finds the disconnected slave from a
+// host with the lowest ID.
+//
+// The caller must hold s.lock.
+func (s *SlaveTracker) disconnectedFrom(host string) *TrackedSlave {
+	var res *TrackedSlave
+	for _, slave := range s.slaves {
+		slave.lock.Lock()
+		match := !slave.connected && slaveHost(slave.Addr) == host
+		slave.lock.Unlock()
+		if match && (res == nil || slave.ID < res.ID) {
+			res = slave
+		}
+	}
+	return res
+}
+
+// slaveHost strips the port from a slave's address, since
+// a reconnecting slave uses a new port.
+func slaveHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// SlaveStatus is a snapshot of a slave's health.
+type SlaveStatus struct {
+	ID             int
+	Addr           string
+	Joined         time.Time
+	Connected      bool
+	Reconnects     int
+	Busy           bool
+	Rollouts       int
+	Errors         int
+	Timeouts       int
+	RolloutsPerSec float64
+	LastError      string `json:",omitempty"`
+}
+
+// TrackedSlave is an anyes.Slave which enforces a rollout
+// deadline and records health statistics.
+//
+// When a rollout fails or times out, the error is passed
+// to the Master, which drops the slave and reassigns the
+// rollout to another slave.
+//
+// Errors and timeouts are counted since the first time
+// the slave's host connected, while rollouts are counted
+// for the current connection.
+type TrackedSlave struct {
+	anyes.Slave
+
+	ID      int
+	Addr    string
+	Timeout time.Duration
+
+	closer    io.Closer
+	closeOnce sync.Once
+
+	lock       sync.Mutex
+	joined     time.Time
+	left       time.Time
+	connected  bool
+	reconnects int
+	ready      bool
+	busy       bool
+	rollouts   int
+	errors     int
+	timeouts   int
+	lastError  string
+}
+
+// Run runs a rollout, failing if the slave does not
+// finish before the deadline.
+func (t *TrackedSlave) Run(stop *anyes.StopConds, scale float64,
+	seed int64) (*anyes.Rollout, error) {
+	t.lock.Lock()
+	t.busy = true
+	t.lock.Unlock()
+
+	type result struct {
+		Rollout *anyes.Rollout
+		Err     error
+	}
+	resChan := make(chan result, 1)
+	go func() {
+		r, err := t.Slave.Run(stop, scale, seed)
+		resChan <- result{r, err}
+	}()
+
+	var timeout <-chan time.Time
+	if t.Timeout != 0 {
+		timer := time.NewTimer(t.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case res := <-resChan:
+		t.lock.Lock()
+		t.busy = false
+		if res.Err != nil {
+			t.errors++
+			t.lastError = res.Err.Error()
+		} else {
+			t.rollouts++
+		}
+		t.lock.Unlock()
+		return res.Rollout, res.Err
+	case <-timeout:
+		err := fmt.Errorf("rollout timed out after %v", t.Timeout)
+		t.lock.Lock()
+		t.busy = false
+		t.timeouts++
+		t.lastError = err.Error()
+		t.lock.Unlock()
+
+		// Disconnecting unblocks the pending Run call.
+		t.Close()
+
+		return nil, err
+	}
+}
+
+// SetReady marks the slave as initialized, meaning that it
+// has joined the master and can run rollouts.
+func (t *TrackedSlave) SetReady() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.ready = true
+}
+
+// Close disconnects the slave.
+// The slave's statistics remain in its tracker.
+func (t *TrackedSlave) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		t.lock.Lock()
+		t.connected = false
+		t.busy = false
+		t.left = time.Now()
+		t.lock.Unlock()
+		if t.closer != nil {
+			err = t.closer.Close()
+		}
+	})
+	return err
+}
+
+// Status returns a snapshot of the slave's health.
+func (t *TrackedSlave) Status() *SlaveStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	end := time.Now()
+	if !t.connected {
+		end = t.left
+	}
+	return &SlaveStatus{
+		ID:             t.ID,
+		Addr:           t.Addr,
+		Joined:         t.joined,
+		Connected:      t.connected,
+		Reconnects:     t.reconnects,
+		Busy:           t.busy,
+		Rollouts:       t.rollouts,
+		Errors:         t.errors,
+		Timeouts:       t.timeouts,
+		RolloutsPerSec: float64(t.rollouts) / end.Sub(t.joined).Seconds(),
+		LastError:      t.lastError,
+	}
+}
+
+func (t *TrackedSlave) String() string {
+	return fmt.Sprintf("slave %d (%s)", t.ID, t.Addr)
+}
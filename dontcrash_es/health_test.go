@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/unixpickle/anyrl/anyes"
+)
+
+type failingSlave struct {
+	anyes.Slave
+}
+
+func (f *failingSlave) Run(stop *anyes.StopConds, scale float64,
+	seed int64) (*anyes.Rollout, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestSlaveTrackerReconnect(t *testing.T) {
+	tracker := &SlaveTracker{}
+	slave := tracker.Track(&failingSlave{}, "10.0.0.1:5000", nil)
+	slave.SetReady()
+	if _, err := slave.Run(nil, 1, 1); err == nil {
+		t.Fatal("expected an error")
+	}
+	slave.Close()
+
+	if len(tracker.Ready()) != 0 {
+		t.Error("disconnected slave is still ready")
+	}
+	status := tracker.Status()
+	if len(status) != 1 || status[0].Connected || status[0].Errors != 1 {
+		t.Fatalf("unexpected status after disconnect: %+v", status)
+	}
+
+	other := tracker.Track(&failingSlave{}, "10.0.0.2:5000", nil)
+	reconnected := tracker.Track(&failingSlave{}, "10.0.0.1:5001", nil)
+	if reconnected.ID != slave.ID || other.ID == slave.ID {
+		t.Errorf("unexpected IDs: original=%d other=%d reconnected=%d", slave.ID,
+			other.ID, reconnected.ID)
+	}
+	status = tracker.Status()
+	if len(status) != 2 {
+		t.Fatalf("expected 2 slaves but got %d", len(status))
+	}
+	s := reconnected.Status()
+	if !s.Connected || s.Errors != 1 || s.Reconnects != 1 {
+		t.Errorf("unexpected status after reconnect: %+v", s)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyconv"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+const histogramWidth = 40
+
+func ParamsMain(args []string) {
+	rand.Seed(time.Now().UnixNano())
+
+	var saveFile string
+	var initialStats bool
+	var a3c bool
+	var diffFile string
+	var initFile string
+	var bins int
+	var explode float64
+	fs := flag.NewFlagSet("params", flag.ExitOnError)
+	fs.StringVar(&saveFile, "file", "trained_policy", "network output file")
+	fs.BoolVar(&initialStats, "initial", false, "dump stats for random network")
+	fs.BoolVar(&a3c, "a3c", false, "load an anya3c (base, actor, critic) triplet")
+	fs.StringVar(&diffFile, "diff", "", "checkpoint to diff against")
+	fs.StringVar(&initFile, "init", "",
+		"checkpoint to detect explosions against (default: a random network)")
+	fs.IntVar(&bins, "hist", 10, "histogram bins per layer (0 to disable)")
+	fs.Float64Var(&explode, "explode", 10, "RMS growth factor that counts as exploded")
+	fs.Parse(args)
+
+	var layers []*LayerInfo
+	if initialStats {
+		log.Println("Analyzing random policy...")
+		layers = DescribeModel(randomModel())
+	} else {
+		log.Println("Analyzing policy...")
+		model, err := LoadModel(saveFile, a3c)
+		if err != nil {
+			essentials.Die(err)
+		}
+		layers = DescribeModel(model)
+	}
+
+	fmt.Println("Architecture:")
+	printSummary(layers)
+
+	var reference []*LayerInfo
+	if initFile != "" {
+		model, err := LoadModel(initFile, a3c)
+		if err != nil {
+			essentials.Die(err)
+		}
+		reference = DescribeModel(model)
+	} else if !a3c && !initialStats {
+		reference = DescribeModel(randomModel())
+	}
+	if reference != nil && !sameShapes(layers, reference) {
+		log.Println("Reference has a different architecture; not checking for explosions.")
+		reference = nil
+	}
+	fmt.Println()
+	fmt.Println("Health:")
+	printHealth(layers, reference, explode)
+
+	if bins > 0 {
+		fmt.Println()
+		fmt.Println("Histograms:")
+		printHistograms(layers, bins)
+	}
+
+	if diffFile != "" {
+		model, err := LoadModel(diffFile, a3c)
+		if err != nil {
+			essentials.Die(err)
+		}
+		other := DescribeModel(model)
+		if !sameShapes(layers, other) {
+			essentials.Die("cannot diff models with different architectures")
+		}
+		fmt.Println()
+		fmt.Println("Diff against " + diffFile + ":")
+		printDiff(layers, other)
+	}
+}
+
+// A Component is a named part of a saved model.
+type Component struct {
+	Name  string
+	Model interface{}
+}
+
+// LoadModel loads either an anyrnn.Stack or an anya3c
+// (base, actor, critic) triplet.
+func LoadModel(path string, a3c bool) ([]*Component, error) {
+	if a3c {
+		var base, actor, critic anyrnn.Block
+		if err := serializer.LoadAny(path, &base, &actor, &critic); err != nil {
+			return nil, essentials.AddCtx("load model", err)
+		}
+		return []*Component{
+			{Name: "base", Model: base},
+			{Name: "actor", Model: actor},
+			{Name: "critic", Model: critic},
+		}, nil
+	}
+	var stack anyrnn.Stack
+	if err := serializer.LoadAny(path, &stack); err != nil {
+		return nil, essentials.AddCtx("load model", err)
+	}
+	return []*Component{{Name: "policy", Model: stack}}, nil
+}
+
+func randomModel() []*Component {
+	return []*Component{
+		{Name: "policy", Model: createNetwork(anyvec32.CurrentCreator())},
+	}
+}
+
+// LayerInfo describes one layer of a model.
+type LayerInfo struct {
+	Name   string
+	Type   string
+	Shape  string
+	Params []*anydiff.Var
+}
+
+// DescribeModel flattens a model into its layers.
+func DescribeModel(components []*Component) []*LayerInfo {
+	var res []*LayerInfo
+	for _, c := range components {
+		res = append(res, describeLayers(c.Name, c.Model)...)
+	}
+	return res
+}
+
+// NumParams counts the layer's parameters.
+func (l *LayerInfo) NumParams() int {
+	var res int
+	for _, p := range l.Params {
+		res += p.Vector.Len()
+	}
+	return res
+}
+
+// Values returns all of the layer's parameters in one
+// slice.
+func (l *LayerInfo) Values() []float64 {
+	var res []float64
+	for _, p := range l.Params {
+		res = append(res, vecFloats(p.Vector)...)
+	}
+	return res
+}
+
+func describeLayers(name string, obj interface{}) []*LayerInfo {
+	var res []*LayerInfo
+	switch obj := obj.(type) {
+	case anyrnn.Stack:
+		for i, block := range obj {
+			res = append(res, describeLayers(fmt.Sprintf("%s.%d", name, i), block)...)
+		}
+	case *anyrnn.LayerBlock:
+		res = describeLayers(name, obj.Layer)
+	case anynet.Net:
+		for i, layer := range obj {
+			res = append(res, describeLayers(fmt.Sprintf("%s.%d", name, i), layer)...)
+		}
+	default:
+		res = []*LayerInfo{{
+			Name:   name,
+			Type:   fmt.Sprintf("%T", obj),
+			Shape:  layerShape(obj),
+			Params: anynet.AllParameters(obj),
+		}}
+	}
+	return res
+}
+
+func layerShape(obj interface{}) string {
+	switch layer := obj.(type) {
+	case *anyconv.Conv:
+		return fmt.Sprintf("in=%dx%dx%d filters=%dx%dx%d stride=%dx%d",
+			layer.InputWidth, layer.InputHeight, layer.InputDepth,
+			layer.FilterCount, layer.FilterWidth, layer.FilterHeight,
+			layer.StrideX, layer.StrideY)
+	case *anynet.FC:
+		return fmt.Sprintf("in=%d out=%d", layer.InCount, layer.OutCount)
+	}
+	params := anynet.AllParameters(obj)
+	if len(params) == 0 {
+		return ""
+	}
+	var sizes []string
+	for _, p := range params {
+		sizes = append(sizes, strconv.Itoa(p.Vector.Len()))
+	}
+	return "params=[" + strings.Join(sizes, ",") + "]"
+}
+
+func sameShapes(layers1, layers2 []*LayerInfo) bool {
+	if len(layers1) != len(layers2) {
+		return false
+	}
+	for i, l1 := range layers1 {
+		l2 := layers2[i]
+		if l1.Type != l2.Type || len(l1.Params) != len(l2.Params) {
+			return false
+		}
+		for j, p := range l1.Params {
+			if p.Vector.Len() != l2.Params[j].Vector.Len() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+type valueStats struct {
+	RMS       float64
+	MaxAbs    float64
+	NonFinite int
+}
+
+func computeValueStats(values []float64) *valueStats {
+	res := &valueStats{}
+	var count int
+	for _, x := range values {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			res.NonFinite++
+			continue
+		}
+		res.RMS += x * x
+		res.MaxAbs = math.Max(res.MaxAbs, math.Abs(x))
+		count++
+	}
+	if count > 0 {
+		res.RMS = math.Sqrt(res.RMS / float64(count))
+	}
+	return res
+}
+
+func printSummary(layers []*LayerInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "layer\ttype\tshape\tparams\trms\tmax|w|")
+	var total int
+	for _, l := range layers {
+		if len(l.Params) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t%s\t0\t\t\n", l.Name, l.Type, l.Shape)
+			continue
+		}
+		stats := computeValueStats(l.Values())
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.4g\t%.4g\n", l.Name, l.Type, l.Shape,
+			l.NumParams(), stats.RMS, stats.MaxAbs)
+		total += l.NumParams()
+	}
+	w.Flush()
+	fmt.Println("total parameters:", total)
+}
+
+func printHealth(layers, reference []*LayerInfo, explode float64) {
+	var problems int
+	for i, l := range layers {
+		if len(l.Params) == 0 {
+			continue
+		}
+		stats := computeValueStats(l.Values())
+		if stats.NonFinite > 0 {
+			fmt.Printf("%s: %d of %d parameters are NaN or Inf\n", l.Name,
+				stats.NonFinite, l.NumParams())
+			problems++
+		}
+		if reference != nil {
+			refStats := computeValueStats(reference[i].Values())
+			if refStats.RMS > 0 && stats.RMS > explode*refStats.RMS {
+				fmt.Printf("%s: RMS grew %.1fx since initialization (%.4g -> %.4g)\n",
+					l.Name, stats.RMS/refStats.RMS, refStats.RMS, stats.RMS)
+				problems++
+			}
+		}
+	}
+	if problems == 0 {
+		fmt.Println("no problems found")
+	}
+}
+
+func printHistograms(layers []*LayerInfo, bins int) {
+	for _, l := range layers {
+		if len(l.Params) == 0 {
+			continue
+		}
+		min, max := math.Inf(1), math.Inf(-1)
+		var values []float64
+		for _, x := range l.Values() {
+			if !math.IsNaN(x) && !math.IsInf(x, 0) {
+				values = append(values, x)
+				min = math.Min(min, x)
+				max = math.Max(max, x)
+			}
+		}
+		fmt.Printf("%s (%s):\n", l.Name, l.Type)
+		if len(values) == 0 {
+			fmt.Println("  no finite values")
+			continue
+		}
+		counts := make([]int, bins)
+		for _, x := range values {
+			idx := bins - 1
+			if max > min {
+				idx = int(float64(bins) * (x - min) / (max - min))
+				if idx == bins {
+					idx--
+				}
+			}
+			counts[idx]++
+		}
+		var maxCount int
+		for _, c := range counts {
+			if c > maxCount {
+				maxCount = c
+			}
+		}
+		for i, c := range counts {
+			binStart := min + (max-min)*float64(i)/float64(bins)
+			bar := strings.Repeat("#", c*histogramWidth/maxCount)
+			fmt.Printf("  %11.4g | %-*s %d\n", binStart, histogramWidth, bar, c)
+		}
+	}
+}
+
+func printDiff(layers, other []*LayerInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "layer\t|change|\trelative\tmax|change|")
+	for i, l := range layers {
+		if len(l.Params) == 0 {
+			continue
+		}
+		values := l.Values()
+		otherValues := other[i].Values()
+		var changeSq, normSq, maxChange float64
+		for j, x := range values {
+			d := x - otherValues[j]
+			changeSq += d * d
+			normSq += otherValues[j] * otherValues[j]
+			maxChange = math.Max(maxChange, math.Abs(d))
+		}
+		// Zero-initialized layers (like the FC head) have no
+		// baseline norm to compare against.
+		relative := "n/a"
+		if normSq > 0 {
+			relative = fmt.Sprintf("%.4g", math.Sqrt(changeSq/normSq))
+		}
+		fmt.Fprintf(w, "%s\t%.4g\t%s\t%.4g\n", l.Name, math.Sqrt(changeSq), relative,
+			maxChange)
+	}
+	w.Flush()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/unixpickle/anyrl/anyes"
+	"github.com/unixpickle/essentials"
+)
+
+// LocalMain runs a master and its slaves in one process.
+//
+// It uses the same flags and checkpoints as MasterMain,
+// so a run can be moved between local and distributed
+// training.
+func LocalMain(args []string) {
+	rand.Seed(time.Now().UnixNano())
+
+	var flags MasterFlags
+	var numWorkers int
+	fs := flag.NewFlagSet("local", flag.ExitOnError)
+	flags.Add(fs)
+	fs.IntVar(&numWorkers, "workers", 1, "number of in-process slaves")
+	fs.Parse(args)
+
+	if numWorkers < 1 {
+		essentials.Die("-workers must be at least 1")
+	}
+
+	session := NewSession(&flags)
+
+	// All of the slaves share one copy of the noise.
+	group := &anyes.NoiseGroup{}
+	pool := &localPool{
+		Tracker:  session.Slaves,
+		AddSlave: session.Master.AddSlave,
+		NewSlave: func() (anyes.Slave, io.Closer) {
+			anynetSlave, env := newSlave(group, flags.Behavior)
+			if session.Behaviors != nil {
+				return withBehavior(anynetSlave, session.Behaviors), env
+			}
+			return anynetSlave, env
+		},
+	}
+	for i := 0; i < numWorkers; i++ {
+		if err := pool.Start(fmt.Sprintf("local-%d", i)); err != nil {
+			essentials.Die(err)
+		}
+	}
+
+	session.Run()
+}
+
+// localPool runs in-process slaves, replacing each slave
+// once the master drops it.
+//
+// Without replacements, a local run would stop for good
+// once every worker had timed out or failed once.
+type localPool struct {
+	Tracker  *SlaveTracker
+	AddSlave func(slave anyes.Slave) error
+
+	// NewSlave creates a slave and the environment which
+	// it runs in.
+	// Closing the environment interrupts a timed-out
+	// rollout.
+	NewSlave func() (anyes.Slave, io.Closer)
+}
+
+// Start adds a new slave to the master.
+//
+// The slave is replaced by a fresh one, with the same
+// name, when a rollout fails or times out.
+func (l *localPool) Start(name string) error {
+	slave, env := l.NewSlave()
+	closer := &replacingCloser{Closer: env, Replace: func() {
+		if err := l.Start(name); err != nil {
+			log.Println("replace", name, "-", err)
+		}
+	}}
+	tracked := l.Tracker.Track(slave, name, closer)
+	if err := l.AddSlave(&localSlave{tracked}); err != nil {
+		closer.Replace = nil
+		tracked.Close()
+		return err
+	}
+	tracked.SetReady()
+	log.Println(tracked, "joined")
+	return nil
+}
+
+// localSlave disconnects a tracked slave after any failed
+// rollout, since the master drops failed slaves.
+type localSlave struct {
+	*TrackedSlave
+}
+
+func (l *localSlave) Run(stop *anyes.StopConds, scale float64,
+	seed int64) (*anyes.Rollout, error) {
+	r, err := l.TrackedSlave.Run(stop, scale, seed)
+	if err != nil {
+		l.TrackedSlave.Close()
+	}
+	return r, err
+}
+
+// replacingCloser closes an environment and then starts a
+// replacement slave in the background, if Replace is set.
+type replacingCloser struct {
+	Closer  io.Closer
+	Replace func()
+}
+
+func (r *replacingCloser) Close() error {
+	err := r.Closer.Close()
+	if r.Replace != nil {
+		go r.Replace()
+	}
+	return err
+}
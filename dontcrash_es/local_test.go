@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/unixpickle/anyrl/anyes"
+)
+
+// hangingSlave blocks in Run until its environment is
+// closed.
+type hangingSlave struct {
+	anyes.Slave
+	closed chan struct{}
+}
+
+func (h *hangingSlave) Run(stop *anyes.StopConds, scale float64,
+	seed int64) (*anyes.Rollout, error) {
+	<-h.closed
+	return nil, errors.New("environment closed")
+}
+
+func (h *hangingSlave) Close() error {
+	close(h.closed)
+	return nil
+}
+
+func TestLocalPoolReplace(t *testing.T) {
+	tracker := &SlaveTracker{Timeout: time.Millisecond * 10}
+	added := make(chan anyes.Slave, 2)
+	pool := &localPool{
+		Tracker: tracker,
+		AddSlave: func(slave anyes.Slave) error {
+			added <- slave
+			return nil
+		},
+		NewSlave: func() (anyes.Slave, io.Closer) {
+			slave := &hangingSlave{closed: make(chan struct{})}
+			return slave, slave
+		},
+	}
+	if err := pool.Start("local-0"); err != nil {
+		t.Fatal(err)
+	}
+	first := <-added
+	if _, err := first.Run(nil, 1, 1); err == nil {
+		t.Fatal("expected a timeout")
+	}
+
+	select {
+	case <-added:
+	case <-time.After(time.Second):
+		t.Fatal("no replacement slave joined")
+	}
+
+	// The replacement is marked ready after it is added.
+	var ready []*TrackedSlave
+	for i := 0; i < 100 && len(ready) == 0; i++ {
+		time.Sleep(time.Millisecond * 10)
+		ready = tracker.Ready()
+	}
+	if len(ready) != 1 {
+		t.Fatalf("expected 1 ready slave but got %d", len(ready))
+	}
+	status := ready[0].Status()
+	if status.Timeouts != 1 || status.Reconnects != 1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
@@ -1,29 +1,23 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"log"
-	"math/rand"
-	"net"
 	"os"
-	"sync"
 	"time"
 
 	"github.com/unixpickle/anynet"
 	"github.com/unixpickle/anynet/anyconv"
 	"github.com/unixpickle/anynet/anymisc"
 	"github.com/unixpickle/anynet/anyrnn"
-	"github.com/unixpickle/anyrl/anyes"
 	"github.com/unixpickle/anyvec"
-	"github.com/unixpickle/anyvec/anyvec32"
 	"github.com/unixpickle/essentials"
-	"github.com/unixpickle/muniverse"
 	"github.com/unixpickle/serializer"
 )
 
 const (
-	TimePerStep = time.Second / 10
+	TimePerStep     = time.Second / 10
+	MaxRolloutSteps = 600
 )
 
 func main() {
@@ -33,7 +27,9 @@ func main() {
 		fmt.Fprintln(os.Stderr, "Available commands:")
 		fmt.Fprintln(os.Stderr, " master   host a master node")
 		fmt.Fprintln(os.Stderr, " slave    host a slave node")
-		fmt.Fprintln(os.Stderr, " params   analyze parameter magnitudes")
+		fmt.Fprintln(os.Stderr, " local    run a master and slaves in one process")
+		fmt.Fprintln(os.Stderr, " params   inspect a saved model")
+		fmt.Fprintln(os.Stderr, " record   record episodes of a saved policy")
 		os.Exit(1)
 	}
 
@@ -42,166 +38,17 @@ func main() {
 		MasterMain(os.Args[2:])
 	case "slave":
 		SlaveMain(os.Args[2:])
+	case "local":
+		LocalMain(os.Args[2:])
 	case "params":
 		ParamsMain(os.Args[2:])
+	case "record":
+		RecordMain(os.Args[2:])
 	default:
 		essentials.Die("unknown subcommand:", os.Args[1])
 	}
 }
 
-func MasterMain(args []string) {
-	rand.Seed(time.Now().UnixNano())
-
-	var saveFile string
-	var batchesPerUpdate int
-	var batchSize int
-	var listenAddr string
-	var stepSize float64
-	var noiseStddev float64
-	fs := flag.NewFlagSet("master", flag.ExitOnError)
-	fs.StringVar(&saveFile, "file", "trained_policy", "network output file")
-	fs.IntVar(&batchesPerUpdate, "updates", 32, "batches per update")
-	fs.IntVar(&batchSize, "batch", 16, "batch size (per log)")
-	fs.StringVar(&listenAddr, "addr", ":1337", "address for listener")
-	fs.Float64Var(&stepSize, "step", 0.03, "step size")
-	fs.Float64Var(&noiseStddev, "stddev", 0.01, "mutation stddev")
-	fs.Parse(args)
-
-	creator := anyvec32.CurrentCreator()
-
-	policy := loadOrCreateNetwork(creator, saveFile)
-
-	// Setup the main coordinator for Evolution Strategies.
-	master := &anyes.Master{
-		Noise: anyes.NewNoise(1337, 1<<23),
-		Params: anyes.MakeSafe(&anyes.AnynetParams{
-			Params: anynet.AllParameters(policy),
-		}),
-		Normalize:   true,
-		NoiseStddev: noiseStddev,
-		StepSize:    stepSize,
-		SlaveError: func(s anyes.Slave, e error) error {
-			log.Println("slave disconnect:", e)
-			s.(anyes.SlaveProxy).Close()
-			return nil
-		},
-	}
-
-	// Listen for incoming slaves.
-	listener, err := net.Listen("tcp", listenAddr)
-	if err != nil {
-		essentials.Die(err)
-	}
-	log.Println("Listening on " + listenAddr)
-	go anyes.ProxyListen(listener, master, log.Println)
-
-	for {
-		log.Println("Gathering batch of experience...")
-		var bigBatch []*anyes.Rollout
-		for i := 0; i < batchesPerUpdate; i++ {
-			stopCond := &anyes.StopConds{MaxSteps: 600}
-			batch, err := master.Rollouts(stopCond, batchSize/2)
-			must(err)
-			log.Printf("sub_mean=%f", anyes.MeanReward(batch))
-			bigBatch = append(bigBatch, batch...)
-		}
-		log.Printf("mean=%f", anyes.MeanReward(bigBatch))
-		must(master.Update(bigBatch))
-
-		must(serializer.SaveAny(saveFile, policy))
-	}
-}
-
-func SlaveMain(args []string) {
-	var masterAddr string
-	var numSlaves int
-	fs := flag.NewFlagSet("slave", flag.ExitOnError)
-	fs.StringVar(&masterAddr, "addr", "", "address for master")
-	fs.IntVar(&numSlaves, "num", 1, "number of slaves")
-	fs.Parse(args)
-
-	if masterAddr == "" {
-		essentials.Die("Missing -addr flag. See -help for more.")
-	}
-
-	var wg sync.WaitGroup
-	group := &anyes.NoiseGroup{}
-	for i := 0; i < numSlaves; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			creator := anyvec32.CurrentCreator()
-			policy := createNetwork(creator)
-
-			spec := muniverse.SpecForName("DontCrash-v0")
-			if spec == nil {
-				panic("environment not found")
-			}
-			env, err := muniverse.NewEnv(spec)
-
-			// Used to debug on my end.
-			//env, err := muniverse.NewEnvChrome("localhost:9222", "localhost:8080", spec)
-
-			must(err)
-			defer env.Close()
-
-			slave := &anyes.AnynetSlave{
-				Params: &anyes.AnynetParams{
-					Params: anynet.AllParameters(policy),
-				},
-				Policy: policy,
-				Env: &PreprocessEnv{
-					Env:     env,
-					Creator: creator,
-				},
-				NoiseGroup: group,
-			}
-			conn, err := net.Dial("tcp", masterAddr)
-			if err != nil {
-				essentials.Die(err)
-			}
-			log.Println("connected a slave to", conn.RemoteAddr())
-			log.Println("disconnected:", anyes.ProxyProvide(conn, slave))
-		}()
-	}
-
-	wg.Wait()
-	log.Println("all slaves disconnected")
-}
-
-func ParamsMain(args []string) {
-	rand.Seed(time.Now().UnixNano())
-
-	var saveFile string
-	var initialStats bool
-	fs := flag.NewFlagSet("params", flag.ExitOnError)
-	fs.StringVar(&saveFile, "file", "trained_policy", "network output file")
-	fs.BoolVar(&initialStats, "initial", false, "dump stats for random network")
-	fs.Parse(args)
-
-	if initialStats {
-		log.Println("Analyzing random policy...")
-		dumpParamStats(createNetwork(anyvec32.CurrentCreator()))
-		return
-	}
-
-	log.Println("Analyzing policy...")
-	var block anyrnn.Stack
-	if err := serializer.LoadAny(saveFile, &block); err != nil {
-		essentials.Die(err)
-	}
-	dumpParamStats(block)
-}
-
-func dumpParamStats(model interface{}) {
-	for i, param := range anynet.AllParameters(model) {
-		sq := param.Vector.Copy()
-		sq.Mul(param.Vector)
-		sq.Scale(sq.Creator().MakeNumeric(1 / float64(sq.Len())))
-		fmt.Println("param", i, "has mean-square", anyvec.Sum(sq))
-	}
-}
-
 func loadOrCreateNetwork(creator anyvec.Creator, path string) anyrnn.Stack {
 	var res anyrnn.Stack
 	if err := serializer.LoadAny(path, &res); err == nil {
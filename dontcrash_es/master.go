@@ -0,0 +1,326 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl/anyes"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/essentials"
+)
+
+// MasterFlags stores the training settings shared by the
+// master and local subcommands.
+type MasterFlags struct {
+	SaveFile         string
+	StateFile        string
+	BatchesPerUpdate int
+	BatchSize        int
+	StepSize         float64
+	StepDecay        float64
+	NoiseStddev      float64
+	NoiseSeed        int64
+	NoiseSize        int
+	Timeout          time.Duration
+	StatusAddr       string
+	Behavior         string
+	NoveltyWeight    float64
+	NoveltyK         int
+	ArchivePerUpdate int
+	EvalInterval     int
+	EvalRollouts     int
+	BestFile         string
+
+	fs *flag.FlagSet
+}
+
+// Add registers the flags with a flag set.
+func (m *MasterFlags) Add(fs *flag.FlagSet) {
+	m.fs = fs
+	fs.StringVar(&m.SaveFile, "file", "trained_policy", "network output file")
+	fs.StringVar(&m.StateFile, "state", "master_state", "master state output file")
+	fs.IntVar(&m.BatchesPerUpdate, "updates", 32, "batches per update")
+	fs.IntVar(&m.BatchSize, "batch", 16, "batch size (per log)")
+	fs.Float64Var(&m.StepSize, "step", 0.03, "step size")
+	fs.Float64Var(&m.StepDecay, "decay", 1, "step size decay per update")
+	fs.Float64Var(&m.NoiseStddev, "stddev", 0.01, "mutation stddev")
+	fs.Int64Var(&m.NoiseSeed, "seed", 1337, "noise table seed")
+	fs.IntVar(&m.NoiseSize, "noise", 1<<23, "noise table size")
+	fs.DurationVar(&m.Timeout, "timeout", time.Minute*5, "rollout deadline (0 for none)")
+	fs.StringVar(&m.StatusAddr, "status", "",
+		"status page address, e.g. localhost:8080 (empty to disable)")
+	fs.StringVar(&m.Behavior, "bc", "",
+		"behavior for novelty search: actions or final (empty to disable)")
+	fs.Float64Var(&m.NoveltyWeight, "novelty", 0.5,
+		"weight of novelty vs. reward (1 for pure novelty search)")
+	fs.IntVar(&m.NoveltyK, "novelty-k", 10, "nearest neighbors for novelty")
+	fs.IntVar(&m.ArchivePerUpdate, "novelty-add", 1, "behaviors archived per update")
+	fs.IntVar(&m.EvalInterval, "eval-every", 5,
+		"updates between evaluations of the unperturbed policy (0 to disable)")
+	fs.IntVar(&m.EvalRollouts, "eval", 8, "rollouts per evaluation")
+	fs.StringVar(&m.BestFile, "best", "best_policy", "file for the best evaluated policy")
+}
+
+// IsSet checks if a flag was passed explicitly.
+func (m *MasterFlags) IsSet(name string) bool {
+	var res bool
+	m.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			res = true
+		}
+	})
+	return res
+}
+
+// BehaviorTimeout is how long the master waits for the
+// behaviors of an update's rollouts.
+const BehaviorTimeout = time.Second * 10
+
+// A Session is a master's training loop and everything it
+// needs to checkpoint and report its progress.
+type Session struct {
+	Flags  *MasterFlags
+	Policy anyrnn.Stack
+	State  *MasterState
+	Master *anyes.Master
+	Slaves *SlaveTracker
+	Status *Status
+
+	// Behaviors and Novelty are nil unless novelty search
+	// is enabled.
+	Behaviors *BehaviorStore
+	Novelty   *NoveltySearch
+}
+
+// NewSession loads or creates a policy and master state
+// and sets up a master for them.
+// It does not add any slaves.
+func NewSession(flags *MasterFlags) *Session {
+	creator := anyvec32.CurrentCreator()
+
+	policy := loadOrCreateNetwork(creator, flags.SaveFile)
+
+	var state *MasterState
+	if _, err := os.Stat(flags.StateFile); err == nil {
+		state, err = LoadMasterState(flags.StateFile)
+		if err != nil {
+			essentials.Die(err)
+		}
+		log.Printf("Resuming from update %d.", state.Iteration)
+		warnOverriddenFlags(flags, state)
+	} else if !os.IsNotExist(err) {
+		essentials.Die(err)
+	} else {
+		state = &MasterState{
+			NoiseSeed:    flags.NoiseSeed,
+			NoiseSize:    flags.NoiseSize,
+			InitStepSize: flags.StepSize,
+			StepDecay:    flags.StepDecay,
+			NoiseStddev:  flags.NoiseStddev,
+		}
+	}
+
+	slaves := &SlaveTracker{Timeout: flags.Timeout}
+
+	// Setup the main coordinator for Evolution Strategies.
+	master := &anyes.Master{
+		Noise: anyes.NewNoise(state.NoiseSeed, state.NoiseSize),
+		Params: anyes.MakeSafe(&anyes.AnynetParams{
+			Params: anynet.AllParameters(policy),
+		}),
+		Normalize:   true,
+		NoiseStddev: state.NoiseStddev,
+		StepSize:    state.StepSize(),
+		SlaveError: func(s anyes.Slave, e error) error {
+			log.Println(s, "disconnect:", e)
+			s.(*TrackedSlave).Close()
+			return nil
+		},
+	}
+
+	res := &Session{
+		Flags:  flags,
+		Policy: policy,
+		State:  state,
+		Master: master,
+		Slaves: slaves,
+		Status: NewStatus(slaves, state.Iteration),
+	}
+
+	if flags.Behavior != "" {
+		if _, err := NewBehaviorFunc(flags.Behavior); err != nil {
+			essentials.Die(err)
+		}
+		res.Behaviors = NewBehaviorStore()
+		res.Novelty = &NoveltySearch{
+			Archive: &NoveltyArchive{
+				K:         flags.NoveltyK,
+				Behaviors: state.Archive,
+			},
+			NoveltyWeight:    flags.NoveltyWeight,
+			ArchivePerUpdate: flags.ArchivePerUpdate,
+		}
+	}
+
+	return res
+}
+
+// warnOverriddenFlags logs the explicitly set flags which
+// are ignored in favor of a resumed state.
+func warnOverriddenFlags(flags *MasterFlags, state *MasterState) {
+	stored := []struct {
+		Flag    string
+		Value   interface{}
+		Ignored interface{}
+	}{
+		{"step", state.InitStepSize, flags.StepSize},
+		{"decay", state.StepDecay, flags.StepDecay},
+		{"stddev", state.NoiseStddev, flags.NoiseStddev},
+		{"seed", state.NoiseSeed, flags.NoiseSeed},
+		{"noise", state.NoiseSize, flags.NoiseSize},
+	}
+	for _, s := range stored {
+		if flags.IsSet(s.Flag) && s.Value != s.Ignored {
+			log.Printf("Ignoring -%s %v: using %v from %s.", s.Flag, s.Ignored, s.Value,
+				flags.StateFile)
+		}
+	}
+}
+
+// Run serves the status page (if enabled) and trains
+// forever.
+func (s *Session) Run() {
+	if s.Flags.StatusAddr != "" {
+		log.Println("Serving status on http://" + s.Flags.StatusAddr)
+		go func() {
+			err := http.ListenAndServe(s.Flags.StatusAddr, s.Status)
+			log.Println("Status page stopped:", err)
+		}()
+	}
+
+	for {
+		log.Println("Gathering batch of experience...")
+		var bigBatch []*anyes.Rollout
+		for i := 0; i < s.Flags.BatchesPerUpdate; i++ {
+			stopCond := &anyes.StopConds{MaxSteps: MaxRolloutSteps}
+			batch, err := s.Master.Rollouts(stopCond, s.Flags.BatchSize/2)
+			must(err)
+			log.Printf("sub_mean=%f", anyes.MeanReward(batch))
+			s.Status.AddRollouts(batch)
+			bigBatch = append(bigBatch, batch...)
+		}
+		log.Printf("update %d: mean=%f step=%f", s.State.Iteration,
+			anyes.MeanReward(bigBatch), s.Master.StepSize)
+
+		updateBatch := bigBatch
+		if s.Novelty != nil {
+			behaviors := s.Behaviors.Take(bigBatch, BehaviorTimeout)
+			var novelty float64
+			updateBatch, novelty = s.Novelty.Fitness(bigBatch, behaviors)
+			var missing int
+			for _, b := range behaviors {
+				if b == nil {
+					missing++
+				}
+			}
+			log.Printf("novelty=%f archive=%d missing=%d", novelty,
+				len(s.Novelty.Archive.Behaviors), missing)
+			s.State.Archive = s.Novelty.Archive.Behaviors
+		}
+		if len(updateBatch) > 0 {
+			must(s.Master.Update(updateBatch))
+		}
+
+		s.State.Iteration++
+		s.Status.AddUpdate(s.State.Iteration, ComputeRewardStats(bigBatch))
+		s.Master.StepSize = s.State.StepSize()
+		if s.Flags.EvalInterval > 0 && s.State.Iteration%s.Flags.EvalInterval == 0 {
+			s.evaluate()
+		}
+		must(SaveCheckpoint(s.Flags.SaveFile, s.Flags.StateFile, s.Policy, s.State))
+	}
+}
+
+func MasterMain(args []string) {
+	rand.Seed(time.Now().UnixNano())
+
+	var flags MasterFlags
+	var listenAddr string
+	var transport TransportFlags
+	fs := flag.NewFlagSet("master", flag.ExitOnError)
+	flags.Add(fs)
+	fs.StringVar(&listenAddr, "addr", ":1337", "address for listener")
+	transport.Add(fs)
+	fs.Parse(args)
+
+	secret, err := transport.Secret()
+	if err != nil {
+		essentials.Die(err)
+	}
+
+	session := NewSession(&flags)
+
+	// Listen for incoming slaves.
+	listener, err := transport.Listen(listenAddr)
+	if err != nil {
+		essentials.Die(err)
+	}
+	log.Println("Listening on " + listenAddr)
+	handshake := &Handshake{
+		Secret:    secret,
+		Arch:      ArchFingerprint(session.Policy),
+		NoiseSeed: session.State.NoiseSeed,
+		NoiseSize: session.State.NoiseSize,
+		Behavior:  flags.Behavior,
+	}
+	go acceptSlaves(listener, handshake, session)
+
+	session.Run()
+}
+
+// acceptSlaves adds incoming connections to the master
+// as tracked slaves, once they pass the handshake.
+//
+// Behavior connections are routed to the session's
+// BehaviorStore.
+func acceptSlaves(l net.Listener, h *Handshake, s *Session) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Println("accept:", err)
+			return
+		}
+		go func() {
+			role, err := h.Server(conn)
+			if err != nil {
+				log.Println("reject", conn.RemoteAddr(), "-", err)
+				conn.Close()
+				return
+			}
+			if role == RoleBehavior {
+				if s.Behaviors == nil {
+					conn.Close()
+				} else {
+					s.Behaviors.Serve(conn)
+				}
+				return
+			}
+			slave := s.Slaves.Track(anyes.ProxyConsume(conn), conn.RemoteAddr().String(),
+				conn)
+			if err := s.Master.AddSlave(slave); err != nil {
+				log.Println(slave, "failed to join:", err)
+				slave.Close()
+				return
+			}
+			slave.SetReady()
+			log.Println(slave, "joined")
+		}()
+	}
+}
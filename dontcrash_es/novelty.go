@@ -0,0 +1,145 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/anyrl/anyes"
+)
+
+// NoveltyArchive stores past behaviors and scores new
+// ones by how different they are.
+type NoveltyArchive struct {
+	// K is the number of nearest neighbors used to score
+	// novelty.
+	K int
+
+	Behaviors []Behavior
+}
+
+// Novelty computes the mean Euclidean distance from a
+// behavior to its K nearest neighbors in the archive.
+//
+// An empty archive makes every behavior equally novel.
+func (n *NoveltyArchive) Novelty(b Behavior) float64 {
+	if len(n.Behaviors) == 0 {
+		return 0
+	}
+	dists := make([]float64, len(n.Behaviors))
+	for i, other := range n.Behaviors {
+		dists[i] = behaviorDist(b, other)
+	}
+	sort.Float64s(dists)
+	k := n.K
+	if k > len(dists) {
+		k = len(dists)
+	}
+	var sum float64
+	for _, d := range dists[:k] {
+		sum += d
+	}
+	return sum / float64(k)
+}
+
+// Add adds a behavior to the archive.
+func (n *NoveltyArchive) Add(b Behavior) {
+	n.Behaviors = append(n.Behaviors, b)
+}
+
+// NoveltySearch turns rewards into a mix of novelty and
+// reward fitness.
+//
+// With a NoveltyWeight of 1, this is NS-ES.
+// With a weight between 0 and 1, it is NSR-ES.
+type NoveltySearch struct {
+	Archive       *NoveltyArchive
+	NoveltyWeight float64
+
+	// ArchivePerUpdate is the number of behaviors from
+	// each update which are added to the archive.
+	ArchivePerUpdate int
+}
+
+// Fitness produces a copy of the rollouts whose rewards
+// are replaced with their mixed fitness.
+//
+// Novelty and reward are converted to centered ranks
+// before they are mixed, so that neither one dominates
+// because of its scale.
+// Rollouts without a behavior get a neutral novelty rank
+// of 0, so that the fitness still lines up with the noise
+// seeds of the rollouts.
+//
+// After computing the fitness, a random sample of the
+// behaviors is added to the archive.
+func (n *NoveltySearch) Fitness(rollouts []*anyes.Rollout,
+	behaviors []Behavior) (fitness []*anyes.Rollout, meanNovelty float64) {
+	var keptIndices []int
+	var keptBehaviors []Behavior
+	var novelties []float64
+	for i, b := range behaviors {
+		if b != nil {
+			keptIndices = append(keptIndices, i)
+			keptBehaviors = append(keptBehaviors, b)
+			novelty := n.Archive.Novelty(b)
+			novelties = append(novelties, novelty)
+			meanNovelty += novelty
+		}
+	}
+	if len(novelties) > 0 {
+		meanNovelty /= float64(len(novelties))
+	}
+
+	novelRanks := make([]float64, len(rollouts))
+	for i, rank := range centeredRanks(novelties) {
+		novelRanks[keptIndices[i]] = rank
+	}
+	rewards := make([]float64, len(rollouts))
+	for i, r := range rollouts {
+		rewards[i] = r.Reward
+	}
+	rewardRanks := centeredRanks(rewards)
+	for i, r := range rollouts {
+		r1 := *r
+		r1.Reward = n.NoveltyWeight*novelRanks[i] + (1-n.NoveltyWeight)*rewardRanks[i]
+		fitness = append(fitness, &r1)
+	}
+
+	for j, i := range rand.Perm(len(keptBehaviors)) {
+		if j >= n.ArchivePerUpdate {
+			break
+		}
+		n.Archive.Add(keptBehaviors[i])
+	}
+
+	return
+}
+
+// centeredRanks maps values to their ranks, scaled to the
+// range [-0.5, 0.5].
+func centeredRanks(values []float64) []float64 {
+	indices := make([]int, len(values))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return values[indices[i]] < values[indices[j]]
+	})
+	res := make([]float64, len(values))
+	if len(values) == 1 {
+		return res
+	}
+	for rank, idx := range indices {
+		res[idx] = float64(rank)/float64(len(values)-1) - 0.5
+	}
+	return res
+}
+
+func behaviorDist(b1, b2 Behavior) float64 {
+	var sum float64
+	for i, x := range b1 {
+		sum += math.Pow(x-b2[i], 2)
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/unixpickle/anyrl/anyes"
+)
+
+func TestNoveltyArchive(t *testing.T) {
+	archive := &NoveltyArchive{
+		K: 2,
+		Behaviors: []Behavior{
+			{0, 0},
+			{3, 4},
+			{10, 0},
+		},
+	}
+	actual := archive.Novelty(Behavior{0, 1})
+	expected := (1 + math.Sqrt(18)) / 2
+	if math.Abs(actual-expected) > 1e-8 {
+		t.Errorf("expected %f but got %f", expected, actual)
+	}
+
+	empty := &NoveltyArchive{K: 2}
+	if n := empty.Novelty(Behavior{1, 2}); n != 0 {
+		t.Errorf("expected 0 but got %f", n)
+	}
+}
+
+func TestCenteredRanks(t *testing.T) {
+	actual := centeredRanks([]float64{3, -1, 7})
+	expected := []float64{0, -0.5, 0.5}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but got %v", expected, actual)
+	}
+}
+
+func TestNoveltySearchFitness(t *testing.T) {
+	ns := &NoveltySearch{
+		Archive:          &NoveltyArchive{K: 1, Behaviors: []Behavior{{0}}},
+		NoveltyWeight:    1,
+		ArchivePerUpdate: 1,
+	}
+	rollouts := []*anyes.Rollout{
+		{Seed: 1, Scale: 1, Reward: 10},
+		{Seed: 2, Scale: 1, Reward: 5},
+		{Seed: 3, Scale: 1, Reward: 0},
+	}
+	behaviors := []Behavior{{1}, nil, {5}}
+	fitness, _ := ns.Fitness(rollouts, behaviors)
+	if len(fitness) != 3 {
+		t.Fatalf("expected 3 rollouts but got %d", len(fitness))
+	}
+	for i, expected := range []float64{-0.5, 0, 0.5} {
+		if fitness[i].Reward != expected || fitness[i].Seed != rollouts[i].Seed {
+			t.Errorf("rollout %d: expected fitness %f but got %f (seed %d)", i,
+				expected, fitness[i].Reward, fitness[i].Seed)
+		}
+	}
+	if rollouts[0].Reward != 10 {
+		t.Error("original rollouts were modified")
+	}
+	if len(ns.Archive.Behaviors) != 2 {
+		t.Errorf("expected 2 archived behaviors but got %d", len(ns.Archive.Behaviors))
+	}
+}
+
+func TestNoveltySearchArchiveSample(t *testing.T) {
+	var rollouts []*anyes.Rollout
+	var behaviors []Behavior
+	for i := 0; i < 10; i++ {
+		rollouts = append(rollouts, &anyes.Rollout{Seed: int64(i), Scale: 1})
+		behaviors = append(behaviors, Behavior{float64(i)})
+	}
+	archived := map[float64]bool{}
+	for i := 0; i < 50; i++ {
+		ns := &NoveltySearch{
+			Archive:          &NoveltyArchive{K: 1},
+			NoveltyWeight:    1,
+			ArchivePerUpdate: 2,
+		}
+		ns.Fitness(rollouts, behaviors)
+		if len(ns.Archive.Behaviors) != 2 {
+			t.Fatalf("expected 2 archived behaviors but got %d", len(ns.Archive.Behaviors))
+		}
+		for _, b := range ns.Archive.Behaviors {
+			archived[b[0]] = true
+		}
+	}
+	if len(archived) <= 2 {
+		t.Errorf("archive is not a random sample: %v", archived)
+	}
+}
@@ -19,6 +19,8 @@ const (
 type PreprocessEnv struct {
 	Env     muniverse.Env
 	Creator anyvec.Creator
+
+	frame []uint8
 }
 
 func (p *PreprocessEnv) Reset() (observation anyvec.Vector, err error) {
@@ -35,6 +37,7 @@ func (p *PreprocessEnv) Reset() (observation anyvec.Vector, err error) {
 	if err != nil {
 		return
 	}
+	p.frame = buffer
 	observation = p.simplifyImage(buffer)
 	return
 }
@@ -72,11 +75,18 @@ func (p *PreprocessEnv) Step(action anyvec.Vector) (observation anyvec.Vector,
 	if err != nil {
 		return
 	}
+	p.frame = buffer
 	observation = p.simplifyImage(buffer)
 
 	return
 }
 
+// Frame returns the raw RGB frame behind the latest
+// observation.
+func (p *PreprocessEnv) Frame() ([]uint8, int, int) {
+	return p.frame, FrameWidth, FrameHeight
+}
+
 func (p *PreprocessEnv) simplifyImage(in []uint8) anyvec.Vector {
 	data := make([]float64, 0, PreprocessedSize)
 	for y := 0; y < FrameHeight; y += 4 {
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/muniverse"
+	"github.com/unixpickle/rl-agents/recorder"
+	"github.com/unixpickle/serializer"
+)
+
+// RecordMain plays a saved policy and records some of its
+// episodes as GIFs or frame sequences.
+func RecordMain(args []string) {
+	rand.Seed(time.Now().UnixNano())
+
+	var saveFile string
+	var outDir string
+	var format string
+	var episodes int
+	var every int
+	var delay int
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	fs.StringVar(&saveFile, "file", "best_policy", "policy to play")
+	fs.StringVar(&outDir, "out", "recordings", "output directory")
+	fs.StringVar(&format, "format", recorder.FormatGIF, "recording format (gif, frames)")
+	fs.IntVar(&episodes, "episodes", 4, "number of episodes to play")
+	fs.IntVar(&every, "every", 1, "record every nth episode")
+	fs.IntVar(&delay, "delay", int(TimePerStep/(time.Second/100)),
+		"GIF frame delay (in 100ths of a second)")
+	fs.Parse(args)
+
+	if every < 1 {
+		essentials.Die("-every must be at least 1")
+	}
+
+	var policy anyrnn.Stack
+	if err := serializer.LoadAny(saveFile, &policy); err != nil {
+		essentials.Die(err)
+	}
+
+	spec := muniverse.SpecForName("DontCrash-v0")
+	if spec == nil {
+		panic("environment not found")
+	}
+	env, err := muniverse.NewEnv(spec)
+	must(err)
+	defer env.Close()
+
+	preproc := &PreprocessEnv{
+		Env:     env,
+		Creator: anyvec32.CurrentCreator(),
+	}
+	recEnv := &recorder.Env{
+		Env:    preproc,
+		Frames: preproc,
+		Recorder: &recorder.Recorder{
+			Dir:    outDir,
+			Format: format,
+			Select: recorder.Every(every),
+			Delay:  delay,
+		},
+	}
+	for i := 0; i < episodes; i++ {
+		reward, steps, err := playEpisode(policy, recEnv)
+		if err != nil {
+			essentials.Die(err)
+		}
+		log.Printf("episode %d: reward=%f steps=%d recorded=%v", i, reward, steps,
+			i%every == 0)
+	}
+	if err := recEnv.Flush(); err != nil {
+		essentials.Die(err)
+	}
+}
+
+// playEpisode runs the unperturbed policy for at most
+// MaxRolloutSteps steps, like the evaluation rollouts.
+func playEpisode(policy anyrnn.Block, env anyrl.Env) (reward float64, steps int,
+	err error) {
+	obs, err := env.Reset()
+	if err != nil {
+		return
+	}
+	state := policy.Start(1)
+	for steps < MaxRolloutSteps {
+		out := policy.Step(state, obs)
+		state = out.State()
+
+		var stepReward float64
+		var done bool
+		obs, stepReward, done, err = env.Step(out.Output())
+		if err != nil {
+			return
+		}
+		reward += stepReward
+		steps++
+		if done {
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/anyrl/anyes"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/muniverse"
+)
+
+func SlaveMain(args []string) {
+	rand.Seed(time.Now().UnixNano())
+
+	var masterAddr string
+	var numSlaves int
+	var minBackoff time.Duration
+	var maxBackoff time.Duration
+	var noiseSeed int64
+	var noiseSize int
+	var behavior string
+	var transport TransportFlags
+	fs := flag.NewFlagSet("slave", flag.ExitOnError)
+	fs.StringVar(&masterAddr, "addr", "", "address for master")
+	fs.IntVar(&numSlaves, "num", 1, "number of slaves")
+	fs.DurationVar(&minBackoff, "retry", time.Second, "initial reconnect delay")
+	fs.DurationVar(&maxBackoff, "maxretry", time.Minute, "maximum reconnect delay")
+	fs.Int64Var(&noiseSeed, "seed", 1337, "expected noise table seed")
+	fs.IntVar(&noiseSize, "noise", 1<<23, "expected noise table size")
+	fs.StringVar(&behavior, "bc", "", "behavior for novelty search (must match master)")
+	transport.Add(fs)
+	fs.Parse(args)
+
+	if masterAddr == "" {
+		essentials.Die("Missing -addr flag. See -help for more.")
+	}
+
+	secret, err := transport.Secret()
+	if err != nil {
+		essentials.Die(err)
+	}
+	handshake := &Handshake{
+		Secret:    secret,
+		Arch:      ArchFingerprint(createNetwork(anyvec32.CurrentCreator())),
+		NoiseSeed: noiseSeed,
+		NoiseSize: noiseSize,
+		Behavior:  behavior,
+		Role:      RoleSlave,
+	}
+
+	behaviorHandshake := *handshake
+	behaviorHandshake.Role = RoleBehavior
+	behaviorClient := &BehaviorClient{
+		Addr:      masterAddr,
+		Transport: &transport,
+		Handshake: &behaviorHandshake,
+	}
+
+	var wg sync.WaitGroup
+	group := &anyes.NoiseGroup{}
+	for i := 0; i < numSlaves; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			anynetSlave, env := newSlave(group, behavior)
+			defer env.Close()
+			slave := withBehavior(anynetSlave, behaviorClient)
+
+			// Keep serving the master across restarts.
+			backoff := minBackoff
+			for {
+				conn, err := transport.Dial(masterAddr)
+				if err == nil {
+					err = handshake.Client(conn)
+					if err != nil {
+						conn.Close()
+					}
+				}
+				if err != nil {
+					if _, ok := err.(*HandshakeError); ok {
+						essentials.Die(err)
+					}
+					log.Printf("connect failed (retry in %v): %v", backoff, err)
+					backoff = sleepBackoff(backoff, maxBackoff)
+					continue
+				}
+				log.Println("connected a slave to", conn.RemoteAddr())
+				backoff = minBackoff
+				err = anyes.ProxyProvide(conn, slave)
+				conn.Close()
+				log.Printf("disconnected (retry in %v): %v", backoff, err)
+				backoff = sleepBackoff(backoff, maxBackoff)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// newSlave creates a slave with its own environment.
+//
+// If behavior is non-empty, the environment is wrapped in
+// a BehaviorEnv.
+//
+// The caller should close the environment when it is
+// done with the slave.
+func newSlave(group *anyes.NoiseGroup, behavior string) (*anyes.AnynetSlave,
+	muniverse.Env) {
+	creator := anyvec32.CurrentCreator()
+	policy := createNetwork(creator)
+
+	spec := muniverse.SpecForName("DontCrash-v0")
+	if spec == nil {
+		panic("environment not found")
+	}
+	env, err := muniverse.NewEnv(spec)
+
+	// Used to debug on my end.
+	//env, err := muniverse.NewEnvChrome("localhost:9222", "localhost:8080", spec)
+
+	must(err)
+
+	slave := &anyes.AnynetSlave{
+		Params: &anyes.AnynetParams{
+			Params: anynet.AllParameters(policy),
+		},
+		Policy: policy,
+		Env: &PreprocessEnv{
+			Env:     env,
+			Creator: creator,
+		},
+		NoiseGroup: group,
+	}
+
+	behaviorFunc, err := NewBehaviorFunc(behavior)
+	if err != nil {
+		essentials.Die(err)
+	}
+	if behaviorFunc != nil {
+		slave.Env = &BehaviorEnv{Env: slave.Env, Func: behaviorFunc}
+	}
+
+	return slave, env
+}
+
+// withBehavior wraps a slave so that it reports its
+// behaviors, if it was created with a behavior.
+func withBehavior(slave *anyes.AnynetSlave, sink BehaviorSink) anyes.Slave {
+	if env, ok := slave.Env.(*BehaviorEnv); ok {
+		return &BehaviorSlave{Slave: slave, Env: env, Sink: sink}
+	}
+	return slave
+}
+
+// sleepBackoff sleeps for roughly the current backoff and
+// returns the next one.
+//
+// The sleep is jittered so that a group of slaves does
+// not reconnect to a restarted master in lock-step.
+func sleepBackoff(cur, max time.Duration) time.Duration {
+	jitter := time.Duration(rand.Int63n(int64(cur)/2 + 1))
+	time.Sleep(cur + jitter)
+	if cur*2 > max {
+		return max
+	}
+	return cur * 2
+}
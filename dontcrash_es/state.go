@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/serializer"
+)
+
+// MasterState stores everything the master needs to
+// resume training, apart from the policy itself.
+type MasterState struct {
+	// Iteration is the number of updates performed so far.
+	Iteration int
+
+	// NoiseSeed and NoiseSize describe the noise table
+	// shared between the master and its slaves.
+	NoiseSeed int64
+	NoiseSize int
+
+	// InitStepSize is decayed by StepDecay after every
+	// update.
+	InitStepSize float64
+	StepDecay    float64
+
+	NoiseStddev float64
+
+	// Archive stores past behaviors for novelty search.
+	Archive []Behavior `json:",omitempty"`
+
+	// BestCenterReward is the best mean reward of the
+	// unperturbed policy, or nil if it has not been
+	// evaluated yet.
+	BestCenterReward *float64 `json:",omitempty"`
+}
+
+// LoadMasterState reads a MasterState from a file.
+func LoadMasterState(path string) (*MasterState, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, essentials.AddCtx("load master state", err)
+	}
+	var res MasterState
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, essentials.AddCtx("load master state", err)
+	}
+	return &res, nil
+}
+
+// StepSize computes the step size at the current point
+// in the schedule.
+func (m *MasterState) StepSize() float64 {
+	return m.InitStepSize * math.Pow(m.StepDecay, float64(m.Iteration))
+}
+
+// Save writes the MasterState to a file.
+//
+// The file is replaced atomically, so a crash during the
+// save will not corrupt an existing state file.
+func (m *MasterState) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return essentials.AddCtx("save master state", err)
+	}
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0644); err != nil {
+		return essentials.AddCtx("save master state", err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return essentials.AddCtx("save master state", err)
+	}
+	return nil
+}
+
+// SaveCheckpoint saves the policy and the master state.
+//
+// The policy is written first, so that a crash between
+// the two writes resumes with an up-to-date policy and a
+// slightly stale iteration count.
+func SaveCheckpoint(policyPath, statePath string, policy anyrnn.Stack,
+	state *MasterState) error {
+	if err := SavePolicy(policyPath, policy); err != nil {
+		return essentials.AddCtx("save checkpoint", err)
+	}
+	return state.Save(statePath)
+}
+
+// SavePolicy atomically replaces a policy file.
+func SavePolicy(path string, policy anyrnn.Stack) error {
+	tempPath := path + ".tmp"
+	if err := serializer.SaveAny(tempPath, policy); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/anyrl/anyes"
+)
+
+// RateWindow is the window over which the master's
+// rollouts per second are measured.
+const RateWindow = time.Minute
+
+// RewardStats summarizes the rewards of a batch.
+type RewardStats struct {
+	Count  int
+	Mean   float64
+	Stddev float64
+	Min    float64
+	Max    float64
+}
+
+// ComputeRewardStats computes statistics for a batch of
+// rollouts.
+func ComputeRewardStats(rollouts []*anyes.Rollout) *RewardStats {
+	res := &RewardStats{
+		Count: len(rollouts),
+		Min:   math.Inf(1),
+		Max:   math.Inf(-1),
+	}
+	if len(rollouts) == 0 {
+		res.Min, res.Max = 0, 0
+		return res
+	}
+	for _, r := range rollouts {
+		res.Mean += r.Reward
+		res.Min = math.Min(res.Min, r.Reward)
+		res.Max = math.Max(res.Max, r.Reward)
+	}
+	res.Mean /= float64(len(rollouts))
+	for _, r := range rollouts {
+		res.Stddev += math.Pow(r.Reward-res.Mean, 2)
+	}
+	res.Stddev = math.Sqrt(res.Stddev / float64(len(rollouts)))
+	return res
+}
+
+// Status tracks the master's progress for the status
+// page.
+type Status struct {
+	Slaves *SlaveTracker
+
+	lock         sync.Mutex
+	started      time.Time
+	iteration    int
+	rollouts     int
+	rolloutTimes []time.Time
+	lastUpdate   *RewardStats
+	lastCenter   *RewardStats
+}
+
+// NewStatus creates a Status for the given slaves.
+func NewStatus(slaves *SlaveTracker, iteration int) *Status {
+	return &Status{
+		Slaves:    slaves,
+		started:   time.Now(),
+		iteration: iteration,
+	}
+}
+
+// AddRollouts records newly gathered rollouts.
+func (s *Status) AddRollouts(rollouts []*anyes.Rollout) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	s.rollouts += len(rollouts)
+	for range rollouts {
+		s.rolloutTimes = append(s.rolloutTimes, now)
+	}
+	for len(s.rolloutTimes) > 0 && now.Sub(s.rolloutTimes[0]) > RateWindow {
+		s.rolloutTimes = s.rolloutTimes[1:]
+	}
+}
+
+// AddUpdate records the statistics for an update.
+func (s *Status) AddUpdate(iteration int, stats *RewardStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.iteration = iteration
+	s.lastUpdate = stats
+}
+
+// AddCenter records the statistics for an evaluation of
+// the unperturbed policy.
+func (s *Status) AddCenter(stats *RewardStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lastCenter = stats
+}
+
+// StatusReport is the JSON body of the status endpoint.
+type StatusReport struct {
+	Uptime         string
+	Iteration      int
+	Rollouts       int
+	RolloutsPerSec float64
+	LastUpdate     *RewardStats
+	LastCenter     *RewardStats
+	Slaves         []*SlaveStatus
+}
+
+// Report produces a snapshot of the status.
+func (s *Status) Report() *StatusReport {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	window := time.Since(s.started)
+	if window > RateWindow {
+		window = RateWindow
+	}
+	return &StatusReport{
+		Uptime:         time.Since(s.started).String(),
+		Iteration:      s.iteration,
+		Rollouts:       s.rollouts,
+		RolloutsPerSec: float64(len(s.rolloutTimes)) / window.Seconds(),
+		LastUpdate:     s.lastUpdate,
+		LastCenter:     s.lastCenter,
+		Slaves:         s.Slaves.Status(),
+	}
+}
+
+// ServeHTTP serves an HTML status page at the root and a
+// JSON status report at /status.json.
+func (s *Status) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		statusTemplate.Execute(w, s.Report())
+	case "/status.json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Report())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!doctype html>
+<html>
+<head>
+<title>dontcrash_es master</title>
+<meta http-equiv="refresh" content="5">
+</head>
+<body>
+<h1>Master status</h1>
+<p>
+Uptime: {{.Uptime}}<br>
+Update: {{.Iteration}}<br>
+Rollouts: {{.Rollouts}} ({{printf "%.2f" .RolloutsPerSec}}/sec)
+</p>
+{{with .LastUpdate}}
+<h2>Last update</h2>
+<p>
+mean={{printf "%.3f" .Mean}} stddev={{printf "%.3f" .Stddev}}
+min={{printf "%.3f" .Min}} max={{printf "%.3f" .Max}} (n={{.Count}})
+</p>
+{{end}}
+{{with .LastCenter}}
+<h2>Last center evaluation</h2>
+<p>
+mean={{printf "%.3f" .Mean}} stddev={{printf "%.3f" .Stddev}}
+min={{printf "%.3f" .Min}} max={{printf "%.3f" .Max}} (n={{.Count}})
+</p>
+{{end}}
+<h2>Slaves ({{len .Slaves}})</h2>
+<table border="1" cellpadding="4">
+<tr><th>ID</th><th>Address</th><th>Joined</th><th>State</th><th>Reconnects</th>
+<th>Busy</th><th>Rollouts</th><th>Rollouts/sec</th><th>Errors</th><th>Timeouts</th>
+<th>Last error</th></tr>
+{{range .Slaves}}
+<tr><td>{{.ID}}</td><td>{{.Addr}}</td><td>{{.Joined.Format "15:04:05"}}</td>
+<td>{{if .Connected}}connected{{else}}disconnected{{end}}</td><td>{{.Reconnects}}</td>
+<td>{{.Busy}}</td><td>{{.Rollouts}}</td><td>{{printf "%.3f" .RolloutsPerSec}}</td>
+<td>{{.Errors}}</td><td>{{.Timeouts}}</td><td>{{.LastError}}</td></tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/unixpickle/anynet"
+	"github.com/unixpickle/essentials"
+)
+
+const (
+	// ProtocolVersion must match between a master and its
+	// slaves.
+	// Bump it whenever the wire protocol or the meaning of
+	// rollouts changes.
+	ProtocolVersion = 2
+
+	HandshakeTimeout = time.Second * 30
+
+	maxFrameSize = 1 << 16
+)
+
+// Connection roles sent by slave processes in the
+// handshake.
+const (
+	RoleSlave    = "slave"
+	RoleBehavior = "behavior"
+)
+
+// TransportFlags configures how masters and slaves
+// connect to each other.
+type TransportFlags struct {
+	UseTLS     bool
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+	SecretFile string
+}
+
+// Add registers the transport flags with a flag set.
+func (t *TransportFlags) Add(fs *flag.FlagSet) {
+	fs.BoolVar(&t.UseTLS, "tls", false, "encrypt connections with TLS")
+	fs.StringVar(&t.CertFile, "cert", "", "TLS certificate file")
+	fs.StringVar(&t.KeyFile, "key", "", "TLS private key file")
+	fs.StringVar(&t.CAFile, "ca", "", "CA file for verifying the other end's certificate")
+	fs.StringVar(&t.SecretFile, "secret", "", "file containing a shared secret")
+}
+
+// Listen creates a listener for a master.
+//
+// If a CA file is provided, slaves must present a
+// certificate signed by it.
+func (t *TransportFlags) Listen(addr string) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil || !t.UseTLS {
+		return l, err
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		l.Close()
+		return nil, errors.New("listen: -tls requires -cert and -key")
+	}
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		l.Close()
+		return nil, essentials.AddCtx("listen", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if t.CAFile != "" {
+		pool, err := t.certPool()
+		if err != nil {
+			l.Close()
+			return nil, essentials.AddCtx("listen", err)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tls.NewListener(l, config), nil
+}
+
+// Dial connects a slave to a master.
+func (t *TransportFlags) Dial(addr string) (net.Conn, error) {
+	if !t.UseTLS {
+		return net.Dial("tcp", addr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, essentials.AddCtx("dial", err)
+	}
+	config := &tls.Config{ServerName: host}
+	if t.CAFile != "" {
+		config.RootCAs, err = t.certPool()
+		if err != nil {
+			return nil, essentials.AddCtx("dial", err)
+		}
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, essentials.AddCtx("dial", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return tls.Dial("tcp", addr, config)
+}
+
+// Secret reads the shared secret, if there is one.
+func (t *TransportFlags) Secret() ([]byte, error) {
+	if t.SecretFile == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(t.SecretFile)
+	if err != nil {
+		return nil, essentials.AddCtx("read secret", err)
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("read secret: secret file is empty")
+	}
+	return data, nil
+}
+
+func (t *TransportFlags) certPool() (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificates in " + t.CAFile)
+	}
+	return pool, nil
+}
+
+// ArchFingerprint summarizes the shapes of a model's
+// parameters, so that masters and slaves with different
+// architectures can detect each other.
+func ArchFingerprint(model interface{}) string {
+	h := sha256.New()
+	for _, param := range anynet.AllParameters(model) {
+		fmt.Fprintf(h, "%d,", param.Vector.Len())
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// A HandshakeError indicates that the other end of a
+// connection was rejected, e.g. because of a version
+// mismatch or a bad secret.
+//
+// Unlike network errors, retrying will not help.
+type HandshakeError struct {
+	Msg string
+}
+
+func (h *HandshakeError) Error() string {
+	return "handshake: " + h.Msg
+}
+
+// Handshake verifies that the two ends of a connection
+// are compatible and share a secret before any rollouts
+// are assigned.
+type Handshake struct {
+	// Secret, if non-nil, must be known to both ends.
+	Secret []byte
+
+	Arch      string
+	NoiseSeed int64
+	NoiseSize int
+
+	// Behavior names the behavior characterization used
+	// for novelty search, or is empty.
+	Behavior string
+
+	// Role is sent by the client to tell the master what
+	// the connection will be used for.
+	Role string
+}
+
+type handshakeMsg struct {
+	Protocol  int
+	Arch      string
+	NoiseSeed int64
+	NoiseSize int
+	Behavior  string
+	Role      string `json:",omitempty"`
+	Nonce     []byte `json:",omitempty"`
+	Proof     []byte `json:",omitempty"`
+	Error     string `json:",omitempty"`
+}
+
+// Server performs the master's end of the handshake.
+// It returns the role requested by the client.
+func (h *Handshake) Server(conn net.Conn) (role string, err error) {
+	defer func() {
+		err = handshakeCtx(err)
+	}()
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	hello := h.hello()
+	if err := writeHandshakeMsg(conn, hello); err != nil {
+		return "", err
+	}
+	remote, err := readHandshakeMsg(conn)
+	if err != nil {
+		return "", err
+	}
+
+	reply := &handshakeMsg{Protocol: ProtocolVersion}
+	rejectErr := h.check(remote)
+	if rejectErr == nil && remote.Role != RoleSlave && remote.Role != RoleBehavior {
+		rejectErr = &HandshakeError{Msg: "unknown role: " + remote.Role}
+	}
+	if rejectErr == nil && !h.verify(remote.Proof, "slave", hello.Nonce, remote.Nonce) {
+		rejectErr = &HandshakeError{Msg: "bad secret from slave"}
+	}
+	if rejectErr != nil {
+		reply.Error = rejectErr.(*HandshakeError).Msg
+		writeHandshakeMsg(conn, reply)
+		return "", rejectErr
+	}
+	reply.Proof = h.prove("master", remote.Nonce, hello.Nonce)
+	return remote.Role, writeHandshakeMsg(conn, reply)
+}
+
+// Client performs the slave's end of the handshake.
+func (h *Handshake) Client(conn net.Conn) (err error) {
+	defer func() {
+		err = handshakeCtx(err)
+	}()
+	conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	remote, err := readHandshakeMsg(conn)
+	if err != nil {
+		return err
+	}
+	if err := h.check(remote); err != nil {
+		return err
+	}
+	hello := h.hello()
+	hello.Role = h.Role
+	hello.Proof = h.prove("slave", remote.Nonce, hello.Nonce)
+	if err := writeHandshakeMsg(conn, hello); err != nil {
+		return err
+	}
+
+	reply, err := readHandshakeMsg(conn)
+	if err != nil {
+		return err
+	}
+	if reply.Error != "" {
+		return &HandshakeError{Msg: "rejected by master: " + reply.Error}
+	}
+	if !h.verify(reply.Proof, "master", hello.Nonce, remote.Nonce) {
+		return &HandshakeError{Msg: "bad secret from master"}
+	}
+	return nil
+}
+
+func handshakeCtx(err error) error {
+	if _, ok := err.(*HandshakeError); ok || err == nil {
+		return err
+	}
+	return essentials.AddCtx("handshake", err)
+}
+
+func (h *Handshake) hello() *handshakeMsg {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return &handshakeMsg{
+		Protocol:  ProtocolVersion,
+		Arch:      h.Arch,
+		NoiseSeed: h.NoiseSeed,
+		NoiseSize: h.NoiseSize,
+		Behavior:  h.Behavior,
+		Nonce:     nonce,
+	}
+}
+
+func (h *Handshake) check(remote *handshakeMsg) error {
+	if remote.Error != "" {
+		return &HandshakeError{Msg: "remote error: " + remote.Error}
+	}
+	if remote.Protocol != ProtocolVersion {
+		return &HandshakeError{
+			Msg: fmt.Sprintf("protocol version %d (expected %d)", remote.Protocol,
+				ProtocolVersion),
+		}
+	}
+	if remote.Arch != h.Arch {
+		return &HandshakeError{
+			Msg: fmt.Sprintf("policy architecture %s (expected %s)", remote.Arch, h.Arch),
+		}
+	}
+	if remote.NoiseSeed != h.NoiseSeed || remote.NoiseSize != h.NoiseSize {
+		return &HandshakeError{
+			Msg: fmt.Sprintf("noise table seed=%d size=%d (expected seed=%d size=%d)",
+				remote.NoiseSeed, remote.NoiseSize, h.NoiseSeed, h.NoiseSize),
+		}
+	}
+	if remote.Behavior != h.Behavior {
+		return &HandshakeError{
+			Msg: fmt.Sprintf("behavior %q (expected %q)", remote.Behavior, h.Behavior),
+		}
+	}
+	return nil
+}
+
+// prove computes a proof that the sender knows the shared
+// secret.
+// The role prevents a proof from being reflected back to
+// its sender.
+func (h *Handshake) prove(role string, nonces ...[]byte) []byte {
+	if h.Secret == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, h.Secret)
+	mac.Write([]byte(role))
+	for _, nonce := range nonces {
+		mac.Write(nonce)
+	}
+	return mac.Sum(nil)
+}
+
+func (h *Handshake) verify(proof []byte, role string, nonces ...[]byte) bool {
+	if h.Secret == nil {
+		return true
+	}
+	return hmac.Equal(proof, h.prove(role, nonces...))
+}
+
+func writeHandshakeMsg(w io.Writer, msg *handshakeMsg) error {
+	return writeFrame(w, msg)
+}
+
+func readHandshakeMsg(r io.Reader) (*handshakeMsg, error) {
+	var res handshakeMsg
+	if err := readFrame(r, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// writeFrame writes a length-prefixed JSON message.
+//
+// Messages are framed explicitly so that no bytes meant
+// for the rollout protocol are consumed by the handshake.
+func writeFrame(w io.Writer, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// readFrame reads a message written by writeFrame.
+func readFrame(r io.Reader, msg interface{}) error {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return err
+	}
+	if size > maxFrameSize {
+		return &HandshakeError{Msg: "message too large"}
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return &HandshakeError{Msg: "malformed message: " + err.Error()}
+	}
+	return nil
+}
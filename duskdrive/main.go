@@ -18,6 +18,7 @@ import (
 	"github.com/unixpickle/lazyseq"
 	"github.com/unixpickle/lazyseq/lazyrnn"
 	"github.com/unixpickle/rip"
+	"github.com/unixpickle/rl-agents/recorder"
 	"github.com/unixpickle/serializer"
 )
 
@@ -31,6 +32,11 @@ const (
 	RenderEnv = false
 
 	NetworkSaveFile = "trained_policy"
+
+	// If RecordDir is set, every RecordEvery-th training
+	// episode is saved there as a GIF.
+	RecordDir   = ""
+	RecordEvery = 20
 )
 
 func main() {
@@ -76,6 +82,16 @@ func main() {
 		},
 	}
 
+	var rec *recorder.Recorder
+	if RecordDir != "" {
+		rec = &recorder.Recorder{
+			Dir:    RecordDir,
+			Format: recorder.FormatGIF,
+			Select: recorder.Every(RecordEvery),
+			Delay:  20,
+		}
+	}
+
 	// Train on a background goroutine so that we can
 	// listen for Ctrl+C on the main goroutine.
 	var trainLock sync.Mutex
@@ -84,7 +100,7 @@ func main() {
 			log.Println("Gathering batch of experience...")
 
 			// Join the rollouts into one set.
-			rollouts := gatherRollouts(roller)
+			rollouts := gatherRollouts(roller, rec)
 			r := anyrl.PackRolloutSets(rollouts)
 
 			// Print the stats for the batch.
@@ -109,7 +125,8 @@ func main() {
 	must(serializer.SaveAny(NetworkSaveFile, policy))
 }
 
-func gatherRollouts(roller *anyrl.RNNRoller) []*anyrl.RolloutSet {
+func gatherRollouts(roller *anyrl.RNNRoller,
+	rec *recorder.Recorder) []*anyrl.RolloutSet {
 	resChan := make(chan *anyrl.RolloutSet, BatchSize)
 
 	requests := make(chan struct{}, BatchSize)
@@ -139,8 +156,16 @@ func gatherRollouts(roller *anyrl.RNNRoller) []*anyrl.RolloutSet {
 				Env:     env,
 				Creator: anynet.AllParameters(roller.Block)[0].Vector.Creator(),
 			}
+			var rolloutEnv anyrl.Env = preproc
+			if rec != nil {
+				recEnv := &recorder.Env{Env: preproc, Frames: preproc, Recorder: rec}
+				defer func() {
+					must(recEnv.Flush())
+				}()
+				rolloutEnv = recEnv
+			}
 			for _ = range requests {
-				rollout, err := roller.Rollout(preproc)
+				rollout, err := roller.Rollout(rolloutEnv)
 				must(err)
 				log.Printf("rollout: sub_reward=%f",
 					rollout.Rewards.Mean())
@@ -20,12 +20,15 @@ type PreprocessEnv struct {
 	Creator anyvec.Creator
 
 	Timestep int
+
+	frame []uint8
 }
 
 func (p *PreprocessEnv) Reset() (observation anyvec.Vector, err error) {
 	rawObs, err := p.Env.Reset()
 	if rawObs != nil {
-		observation = p.simplifyImage(rawObs.(gym.Uint8Obs).Uint8Obs())
+		p.frame = rawObs.(gym.Uint8Obs).Uint8Obs()
+		observation = p.simplifyImage(p.frame)
 	}
 	p.Timestep = 0
 	return
@@ -43,7 +46,8 @@ func (p *PreprocessEnv) Step(action anyvec.Vector) (observation anyvec.Vector,
 	}
 	rawObs, reward, done, _, err := p.Env.Step(events)
 	if rawObs != nil {
-		observation = p.simplifyImage(rawObs.(gym.Uint8Obs).Uint8Obs())
+		p.frame = rawObs.(gym.Uint8Obs).Uint8Obs()
+		observation = p.simplifyImage(p.frame)
 	}
 	p.Timestep++
 	if p.Timestep > MaxTimestep {
@@ -52,6 +56,12 @@ func (p *PreprocessEnv) Step(action anyvec.Vector) (observation anyvec.Vector,
 	return
 }
 
+// Frame returns the raw RGB frame behind the latest
+// observation.
+func (p *PreprocessEnv) Frame() ([]uint8, int, int) {
+	return p.frame, FrameWidth, FrameHeight
+}
+
 func (p *PreprocessEnv) simplifyImage(in []uint8) anyvec.Vector {
 	data := make([]float64, 0, PreprocessedSize)
 	for y := 0; y < FrameHeight; y += 4 {
@@ -0,0 +1,171 @@
+// Package monitor records episode statistics to a run
+// directory, replacing gym's monitor and scoreboard.
+package monitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// EpisodesFile is the name of the episode log inside a
+// run directory.
+const EpisodesFile = "episodes.jsonl"
+
+// An Episode is one line of an episode log.
+type Episode struct {
+	Episode    int       `json:"episode"`
+	Reward     float64   `json:"reward"`
+	Length     int       `json:"length"`
+	Time       time.Time `json:"time"`
+	Checkpoint string    `json:"checkpoint,omitempty"`
+}
+
+// Env wraps an anyrl.Env and logs every finished episode.
+//
+// Episodes which are abandoned by calling Reset early are
+// not logged.
+type Env struct {
+	Env anyrl.Env
+
+	lock       sync.Mutex
+	file       *os.File
+	numLogged  int
+	checkpoint string
+	reward     float64
+	length     int
+}
+
+// NewEnv creates an Env which appends to the episode log
+// in the run directory, creating the directory if needed.
+//
+// Episode numbers continue from any episodes already in
+// the log.
+func NewEnv(env anyrl.Env, dir string) (*Env, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, essentials.AddCtx("create monitor", err)
+	}
+	existing, err := ReadEpisodes(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, essentials.AddCtx("create monitor", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, EpisodesFile),
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, essentials.AddCtx("create monitor", err)
+	}
+	return &Env{Env: env, file: f, numLogged: len(existing)}, nil
+}
+
+// SetCheckpoint sets the checkpoint ID recorded for
+// subsequent episodes.
+func (e *Env) SetCheckpoint(id string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.checkpoint = id
+}
+
+// Reset resets the environment.
+func (e *Env) Reset() (anyvec.Vector, error) {
+	e.lock.Lock()
+	e.reward = 0
+	e.length = 0
+	e.lock.Unlock()
+	return e.Env.Reset()
+}
+
+// Step steps the environment, logging the episode if it
+// is done.
+func (e *Env) Step(action anyvec.Vector) (obs anyvec.Vector, reward float64,
+	done bool, err error) {
+	obs, reward, done, err = e.Env.Step(action)
+	if err != nil {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.reward += reward
+	e.length++
+	if done {
+		err = e.logEpisode()
+	}
+	return
+}
+
+// Close closes the episode log.
+func (e *Env) Close() error {
+	return e.file.Close()
+}
+
+func (e *Env) logEpisode() error {
+	data, err := json.Marshal(&Episode{
+		Episode:    e.numLogged,
+		Reward:     e.reward,
+		Length:     e.length,
+		Time:       time.Now(),
+		Checkpoint: e.checkpoint,
+	})
+	if err != nil {
+		return essentials.AddCtx("log episode", err)
+	}
+	if _, err := e.file.Write(append(data, '\n')); err != nil {
+		return essentials.AddCtx("log episode", err)
+	}
+	e.numLogged++
+	return nil
+}
+
+// ReadEpisodes reads the episode log from a run directory.
+func ReadEpisodes(dir string) ([]*Episode, error) {
+	f, err := os.Open(filepath.Join(dir, EpisodesFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res []*Episode
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ep Episode
+		if err := json.Unmarshal(scanner.Bytes(), &ep); err != nil {
+			return nil, essentials.AddCtx("read episodes", err)
+		}
+		res = append(res, &ep)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, essentials.AddCtx("read episodes", err)
+	}
+	return res, nil
+}
+
+// EpisodesToSolve finds the number of episodes it took for
+// the mean reward over the trailing window to reach the
+// threshold.
+//
+// The second return value is false if the episodes never
+// reached the threshold, or if the window is empty.
+func EpisodesToSolve(eps []*Episode, threshold float64, window int) (int, bool) {
+	if window < 1 {
+		return 0, false
+	}
+	var sum float64
+	for i, ep := range eps {
+		sum += ep.Reward
+		if i >= window {
+			sum -= eps[i-window].Reward
+		}
+		if i+1 >= window && sum/float64(window) >= threshold {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
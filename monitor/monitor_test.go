@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/unixpickle/anyvec"
+)
+
+type countdownEnv struct {
+	steps int
+}
+
+func (c *countdownEnv) Reset() (anyvec.Vector, error) {
+	c.steps = 0
+	return nil, nil
+}
+
+func (c *countdownEnv) Step(action anyvec.Vector) (anyvec.Vector, float64, bool, error) {
+	c.steps++
+	return nil, 2, c.steps == 3, nil
+}
+
+func TestEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for run := 0; run < 2; run++ {
+		env, err := NewEnv(&countdownEnv{}, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.SetCheckpoint("ckpt")
+		for i := 0; i < 2; i++ {
+			env.Reset()
+			for done := false; !done; {
+				if _, _, done, err = env.Step(nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		// An abandoned episode should not be logged.
+		env.Reset()
+		env.Step(nil)
+		env.Reset()
+		env.Close()
+	}
+
+	eps, err := ReadEpisodes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eps) != 4 {
+		t.Fatalf("expected 4 episodes but got %d", len(eps))
+	}
+	for i, ep := range eps {
+		if ep.Episode != i || ep.Reward != 6 || ep.Length != 3 || ep.Checkpoint != "ckpt" {
+			t.Errorf("episode %d: unexpected entry %+v", i, ep)
+		}
+	}
+}
+
+func TestEpisodesToSolve(t *testing.T) {
+	var eps []*Episode
+	for _, r := range []float64{1, 5, 9, 2, 10, 10} {
+		eps = append(eps, &Episode{Reward: r})
+	}
+	tests := []struct {
+		threshold float64
+		window    int
+		expected  int
+		solved    bool
+	}{
+		{9, 1, 3, true},
+		{5, 2, 3, true},
+		{7, 3, 5, true},
+		{11, 1, 0, false},
+		{0, 10, 0, false},
+		{0, 0, 0, false},
+	}
+	for _, test := range tests {
+		n, solved := EpisodesToSolve(eps, test.threshold, test.window)
+		if n != test.expected || solved != test.solved {
+			t.Errorf("threshold %f window %d: got (%d, %v) but expected (%d, %v)",
+				test.threshold, test.window, n, solved, test.expected, test.solved)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Command monitor_summary summarizes the episode logs in
+// one or more run directories.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/rl-agents/monitor"
+)
+
+func main() {
+	var threshold float64
+	var window int
+	flag.Float64Var(&threshold, "threshold", 475, "mean reward which counts as solved")
+	flag.IntVar(&window, "window", 100, "episodes in the solve window")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: monitor_summary [flags] <run_dir> ...")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if window < 1 {
+		essentials.Die("-window must be at least 1")
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	for _, dir := range flag.Args() {
+		eps, err := monitor.ReadEpisodes(dir)
+		if err != nil {
+			essentials.Die(err)
+		}
+		fmt.Println(dir + ":")
+		if len(eps) == 0 {
+			fmt.Println("  no episodes")
+			continue
+		}
+		var total float64
+		var steps int
+		best := math.Inf(-1)
+		for _, ep := range eps {
+			total += ep.Reward
+			steps += ep.Length
+			best = math.Max(best, ep.Reward)
+		}
+		last := eps[len(eps)-1]
+		printField("episodes", fmt.Sprintf("%d (%d steps)", len(eps), steps))
+		printField("duration", last.Time.Sub(eps[0].Time))
+		printField("mean reward", total/float64(len(eps)))
+		printField("best reward", best)
+		if last.Checkpoint != "" {
+			printField("last checkpoint", last.Checkpoint)
+		}
+		if n, ok := monitor.EpisodesToSolve(eps, threshold, window); ok {
+			printField("episodes to solve", n)
+		} else {
+			printField("episodes to solve", "unsolved")
+		}
+	}
+}
+
+func printField(name string, value interface{}) {
+	fmt.Printf("  %-18s %v\n", name+":", value)
+}
@@ -0,0 +1,121 @@
+package recorder
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// StripHeight is the height of the overlay strip below
+// each frame.
+const StripHeight = 16
+
+const (
+	glyphScale = 2
+	cellSize   = 10
+)
+
+var (
+	stripColor    = color.RGBA{R: 0x20, G: 0x20, B: 0x20, A: 0xff}
+	textColor     = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	offColor      = color.RGBA{R: 0x60, G: 0x60, B: 0x60, A: 0xff}
+	positiveColor = color.RGBA{R: 0x40, G: 0xff, B: 0x40, A: 0xff}
+	negativeColor = color.RGBA{R: 0xff, G: 0x40, B: 0x40, A: 0xff}
+)
+
+// glyphs is a 3x5 bitmap font, with one string per row.
+var glyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'-': {"...", "...", "###", "...", "..."},
+	'.': {"...", "...", "...", "...", ".#."},
+	'A': {".#.", "#.#", "###", "#.#", "#.#"},
+	'E': {"###", "#..", "##.", "#..", "###"},
+	'L': {"#..", "#..", "#..", "#..", "###"},
+	'O': {"###", "#.#", "#.#", "#.#", "###"},
+	'P': {"##.", "#.#", "##.", "#..", "#.."},
+	'R': {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S': {".##", "#..", ".#.", "..#", "##."},
+	'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	' ': {"...", "...", "...", "...", "..."},
+}
+
+// drawStrip draws the overlay strip starting at row y.
+//
+// Each action component is drawn as a cell, which is lit
+// if the component is positive (e.g. a one-hot index or a
+// Bernoulli "press").
+func drawStrip(img *image.RGBA, y, step int, action []float64, reward, total float64) {
+	bounds := image.Rect(0, y, img.Bounds().Dx(), y+StripHeight)
+	draw.Draw(img, bounds, image.NewUniform(stripColor), image.ZP, draw.Src)
+
+	top := y + (StripHeight-cellSize)/2
+	x := 4
+	for _, a := range action {
+		cell := image.Rect(x, top, x+cellSize, top+cellSize)
+		if a > 0 {
+			draw.Draw(img, cell, image.NewUniform(textColor), image.ZP, draw.Src)
+		} else {
+			drawOutline(img, cell, offColor)
+		}
+		x += cellSize + 2
+	}
+	if len(action) > 0 {
+		x += 6
+	}
+
+	top = y + (StripHeight-5*glyphScale)/2
+	x = drawText(img, x, top, fmt.Sprintf("STEP %d  ", step), textColor)
+	rewardColor := textColor
+	if reward > 0 {
+		rewardColor = positiveColor
+	} else if reward < 0 {
+		rewardColor = negativeColor
+	}
+	x = drawText(img, x, top, fmt.Sprintf("R %.2f  ", reward), rewardColor)
+	drawText(img, x, top, fmt.Sprintf("TOTAL %.2f", total), textColor)
+}
+
+// drawText draws text and returns the x coordinate after
+// the last glyph.
+func drawText(img *image.RGBA, x, y int, text string, c color.Color) int {
+	for _, ch := range text {
+		glyph, ok := glyphs[ch]
+		if !ok {
+			glyph = glyphs[' ']
+		}
+		for row, bits := range glyph {
+			for col, bit := range bits {
+				if bit != '#' {
+					continue
+				}
+				px := x + col*glyphScale
+				py := y + row*glyphScale
+				rect := image.Rect(px, py, px+glyphScale, py+glyphScale)
+				draw.Draw(img, rect, image.NewUniform(c), image.ZP, draw.Src)
+			}
+		}
+		x += 4 * glyphScale
+	}
+	return x
+}
+
+func drawOutline(img *image.RGBA, r image.Rectangle, c color.Color) {
+	for x := r.Min.X; x < r.Max.X; x++ {
+		img.Set(x, r.Min.Y, c)
+		img.Set(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		img.Set(r.Min.X, y, c)
+		img.Set(r.Max.X-1, y, c)
+	}
+}
@@ -0,0 +1,256 @@
+// Package recorder saves episodes as animated GIFs or PNG
+// frame sequences, with an overlay strip showing the
+// chosen action and the reward at every timestep.
+package recorder
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/unixpickle/anyrl"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/essentials"
+)
+
+// Supported recording formats.
+const (
+	FormatGIF    = "gif"
+	FormatFrames = "frames"
+)
+
+// A FrameSource provides the raw frame behind an
+// environment's latest observation.
+//
+// For example, a preprocessing environment can keep the
+// buffer from muniverse.RGB() or gym.Uint8Obs.
+type FrameSource interface {
+	// Frame returns packed RGB pixels and the frame size.
+	Frame() (rgb []uint8, width, height int)
+}
+
+// A Recorder decides which episodes to record and writes
+// them to a directory.
+//
+// A Recorder may be shared by multiple Envs.
+type Recorder struct {
+	Dir    string
+	Format string
+
+	// Select decides whether to record an episode, given
+	// its index.
+	// If nil, every episode is recorded.
+	Select func(episode int) bool
+
+	// Delay is the GIF frame delay, in 100ths of a second.
+	Delay int
+
+	lock        sync.Mutex
+	numEpisodes int
+}
+
+// Every returns a Select function which records every nth
+// episode, starting with the first.
+// If n is not positive, no episodes are recorded.
+func Every(n int) func(episode int) bool {
+	return func(episode int) bool {
+		return n > 0 && episode%n == 0
+	}
+}
+
+func (r *Recorder) nextEpisode() (int, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	idx := r.numEpisodes
+	r.numEpisodes++
+	return idx, r.Select == nil || r.Select(idx)
+}
+
+// Env wraps an anyrl.Env and records the selected
+// episodes.
+//
+// Episodes which are not selected are not copied, so
+// recording costs nothing outside of selected episodes.
+type Env struct {
+	Env      anyrl.Env
+	Frames   FrameSource
+	Recorder *Recorder
+
+	episode *episode
+}
+
+// Reset resets the environment, saving any unfinished
+// recording.
+func (e *Env) Reset() (anyvec.Vector, error) {
+	if err := e.finish(); err != nil {
+		return nil, err
+	}
+	obs, err := e.Env.Reset()
+	if err != nil {
+		return nil, err
+	}
+	idx, selected := e.Recorder.nextEpisode()
+	if selected {
+		e.episode, err = newEpisode(e.Recorder, idx)
+		if err == nil {
+			err = e.addFrame(nil, 0)
+		}
+	}
+	return obs, err
+}
+
+// Step steps the environment, saving the recording once
+// the episode is done.
+func (e *Env) Step(action anyvec.Vector) (obs anyvec.Vector, reward float64,
+	done bool, err error) {
+	obs, reward, done, err = e.Env.Step(action)
+	if err != nil || e.episode == nil {
+		return
+	}
+	if err = e.addFrame(action, reward); err != nil {
+		return
+	}
+	if done {
+		err = e.finish()
+	}
+	return
+}
+
+// Flush saves the current recording, if there is one.
+//
+// It should be called after the last episode if that
+// episode may not have finished.
+func (e *Env) Flush() error {
+	return e.finish()
+}
+
+func (e *Env) addFrame(action anyvec.Vector, reward float64) error {
+	var actionValues []float64
+	if action != nil {
+		actionValues = vecFloats(action)
+	}
+	rgb, width, height := e.Frames.Frame()
+	return e.episode.Add(rgb, width, height, actionValues, reward)
+}
+
+func (e *Env) finish() error {
+	if e.episode == nil {
+		return nil
+	}
+	err := e.episode.Save()
+	e.episode = nil
+	return err
+}
+
+type episode struct {
+	recorder    *Recorder
+	index       int
+	totalReward float64
+	numFrames   int
+	anim        gif.GIF
+}
+
+func newEpisode(r *Recorder, index int) (*episode, error) {
+	if r.Format != FormatGIF && r.Format != FormatFrames {
+		return nil, errors.New("record episode: unknown format: " + r.Format)
+	}
+	ep := &episode{recorder: r, index: index}
+	dir := r.Dir
+	if r.Format == FormatFrames {
+		dir = ep.path()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, essentials.AddCtx("record episode", err)
+	}
+	return ep, nil
+}
+
+// Add adds a frame and its overlay strip.
+//
+// The action is nil for the first frame of an episode.
+func (e *episode) Add(rgb []uint8, width, height int, action []float64,
+	reward float64) error {
+	if len(rgb) != width*height*3 {
+		return fmt.Errorf("record frame: expected %d bytes but got %d",
+			width*height*3, len(rgb))
+	}
+	e.totalReward += reward
+	img := image.NewRGBA(image.Rect(0, 0, width, height+StripHeight))
+	for i := 0; i < width*height; i++ {
+		copy(img.Pix[i*4:], rgb[i*3:i*3+3])
+		img.Pix[i*4+3] = 0xff
+	}
+	drawStrip(img, height, e.numFrames, action, reward, e.totalReward)
+
+	defer func() {
+		e.numFrames++
+	}()
+	if e.recorder.Format == FormatFrames {
+		return e.writePNG(img)
+	}
+	paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+	draw.Draw(paletted, paletted.Bounds(), img, image.ZP, draw.Src)
+	e.anim.Image = append(e.anim.Image, paletted)
+	e.anim.Delay = append(e.anim.Delay, e.recorder.Delay)
+	return nil
+}
+
+// Save writes the GIF for an episode.
+func (e *episode) Save() error {
+	if e.recorder.Format != FormatGIF {
+		return nil
+	}
+	f, err := os.Create(e.path())
+	if err != nil {
+		return essentials.AddCtx("save recording", err)
+	}
+	defer f.Close()
+	if err := gif.EncodeAll(f, &e.anim); err != nil {
+		return essentials.AddCtx("save recording", err)
+	}
+	return nil
+}
+
+func (e *episode) writePNG(img image.Image) error {
+	path := filepath.Join(e.path(), fmt.Sprintf("frame_%05d.png", e.numFrames))
+	f, err := os.Create(path)
+	if err != nil {
+		return essentials.AddCtx("save frame", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return essentials.AddCtx("save frame", err)
+	}
+	return nil
+}
+
+// path returns the GIF file or the frame directory.
+func (e *episode) path() string {
+	name := fmt.Sprintf("episode_%04d", e.index)
+	if e.recorder.Format == FormatGIF {
+		name += ".gif"
+	}
+	return filepath.Join(e.recorder.Dir, name)
+}
+
+func vecFloats(v anyvec.Vector) []float64 {
+	switch data := v.Data().(type) {
+	case []float64:
+		return data
+	case []float32:
+		res := make([]float64, len(data))
+		for i, x := range data {
+			res[i] = float64(x)
+		}
+		return res
+	default:
+		panic("unsupported numeric type")
+	}
+}
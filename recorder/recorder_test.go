@@ -0,0 +1,164 @@
+package recorder
+
+import (
+	"fmt"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/anyvec/anyvec64"
+)
+
+type testEnv struct {
+	steps int
+	frame []uint8
+}
+
+func (t *testEnv) Reset() (anyvec.Vector, error) {
+	t.steps = 0
+	t.frame = make([]uint8, 120*80*3)
+	return nil, nil
+}
+
+func (t *testEnv) Step(action anyvec.Vector) (anyvec.Vector, float64, bool, error) {
+	t.steps++
+	for i := range t.frame {
+		t.frame[i] = uint8(t.steps * 20)
+	}
+	return nil, 1, t.steps == 4, nil
+}
+
+func (t *testEnv) Frame() ([]uint8, int, int) {
+	return t.frame, 120, 80
+}
+
+func TestEvery(t *testing.T) {
+	sel := Every(3)
+	for i := 0; i < 10; i++ {
+		if expected := i%3 == 0; sel(i) != expected {
+			t.Errorf("episode %d: expected %v but got %v", i, expected, sel(i))
+		}
+	}
+	for _, n := range []int{0, -1} {
+		sel := Every(n)
+		for i := 0; i < 3; i++ {
+			if sel(i) {
+				t.Errorf("Every(%d) selected episode %d", n, i)
+			}
+		}
+	}
+}
+
+func TestEnvGIF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inner := &testEnv{}
+	env := &Env{
+		Env:    inner,
+		Frames: inner,
+		Recorder: &Recorder{
+			Dir:    dir,
+			Format: FormatGIF,
+			Select: Every(2),
+		},
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := env.Reset(); err != nil {
+			t.Fatal(err)
+		}
+		for done := false; !done; {
+			action := anyvec64.MakeVectorData([]float64{0, 1})
+			if _, _, done, err = env.Step(action); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, name := range []string{"episode_0000.gif", "episode_0002.gif"} {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		anim, err := gif.DecodeAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(anim.Image) != 5 {
+			t.Errorf("%s: expected 5 frames but got %d", name, len(anim.Image))
+		}
+		size := anim.Image[0].Bounds().Size()
+		if size.X != 120 || size.Y != 80+StripHeight {
+			t.Errorf("%s: unexpected size %v", name, size)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "episode_0001.gif")); !os.IsNotExist(err) {
+		t.Error("unselected episode was recorded")
+	}
+}
+
+func TestEnvFrames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inner := &testEnv{}
+	env := &Env{
+		Env:    inner,
+		Frames: inner,
+		Recorder: &Recorder{
+			Dir:    dir,
+			Format: FormatFrames,
+		},
+	}
+	if _, err := env.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	for done := false; !done; {
+		action := anyvec64.MakeVectorData([]float64{1, 0})
+		if _, _, done, err = env.Step(action); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	frameDir := filepath.Join(dir, "episode_0000")
+	listing, err := ioutil.ReadDir(frameDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listing) != 5 {
+		t.Fatalf("expected 5 frames but got %d", len(listing))
+	}
+	for i, info := range listing {
+		if expected := fmt.Sprintf("frame_%05d.png", i); info.Name() != expected {
+			t.Errorf("frame %d: expected %s but got %s", i, expected, info.Name())
+			continue
+		}
+		f, err := os.Open(filepath.Join(frameDir, info.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size := img.Bounds().Size(); size.X != 120 || size.Y != 80+StripHeight {
+			t.Errorf("frame %d: unexpected size %v", i, size)
+		}
+		r, _, _, _ := img.At(0, 0).RGBA()
+		if expected := uint32(i*20) * 0x101; r != expected {
+			t.Errorf("frame %d: expected red %d but got %d", i, expected, r)
+		}
+	}
+}
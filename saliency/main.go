@@ -0,0 +1,149 @@
+// Command saliency draws the parts of the screen that the
+// conv agents (dontcrash, knightower, etc.) pay attention
+// to, for single frames or for recorded episodes.
+package main
+
+import (
+	"flag"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/muniverse"
+	"github.com/unixpickle/rl-agents/treepolicy"
+	"github.com/unixpickle/serializer"
+)
+
+func main() {
+	var policyFile string
+	var specName string
+	var stride int
+	var method string
+	var radius int
+	var perturbStride int
+	var framePath string
+	var episodeDir string
+	var outPath string
+	var delay int
+	flag.StringVar(&policyFile, "file", "trained_policy", "saved anyrnn.Stack policy")
+	flag.StringVar(&specName, "spec", "DontCrash-v0", "muniverse environment (for the frame size)")
+	flag.IntVar(&stride, "stride", 4, "downsampling stride of the preprocessor")
+	flag.StringVar(&method, "method", "gradient", "saliency method (gradient, perturb)")
+	flag.IntVar(&radius, "radius", 2, "perturbation radius (in preprocessed pixels)")
+	flag.IntVar(&perturbStride, "perturb-stride", 2, "perturbation spacing (in preprocessed pixels)")
+	flag.StringVar(&framePath, "frame", "", "PNG frame to analyze")
+	flag.StringVar(&episodeDir, "episode", "", "directory of PNG frames from an episode")
+	flag.StringVar(&outPath, "out", "", "output PNG (for -frame) or GIF (for -episode)")
+	flag.IntVar(&delay, "delay", 10, "GIF frame delay (in 100ths of a second)")
+	flag.Parse()
+
+	if (framePath == "") == (episodeDir == "") {
+		essentials.Die("Pass exactly one of -frame or -episode. See -help for more.")
+	}
+	if method != "gradient" && method != "perturb" {
+		essentials.Die("unknown method:", method)
+	}
+
+	spec := muniverse.SpecForName(specName)
+	if spec == nil {
+		essentials.Die("environment not found:", specName)
+	}
+	var policy anyrnn.Stack
+	if err := serializer.LoadAny(policyFile, &policy); err != nil {
+		essentials.Die(err)
+	}
+	s := &Saliency{
+		Policy:  policy,
+		Creator: anyvec32.CurrentCreator(),
+		Grid: &treepolicy.Grid{
+			FrameWidth:  spec.Width,
+			FrameHeight: spec.Height,
+			Stride:      stride,
+		},
+	}
+	compute := func(state anyrnn.State, in []float64) []float64 {
+		if method == "perturb" {
+			return s.Perturbation(state, in, radius, perturbStride)
+		}
+		return s.Gradient(state, in)
+	}
+
+	if framePath != "" {
+		frame, err := treepolicy.LoadFrame(framePath)
+		if err != nil {
+			essentials.Die(err)
+		}
+		rgb, err := s.Grid.ImageRGB(frame)
+		if err != nil {
+			essentials.Die(err)
+		}
+		in := s.Grid.Features(rgb)
+
+		// Without an episode, assume the scene is still, so
+		// the previous frame is the same as this one.
+		state := policy.Step(policy.Start(1), s.vector(in)).State()
+
+		if outPath == "" {
+			outPath = "saliency.png"
+		}
+		heatmap := treepolicy.Heatmap(s.Grid, compute(state, in), frame)
+		if err := treepolicy.SavePNG(outPath, heatmap); err != nil {
+			essentials.Die(err)
+		}
+		return
+	}
+
+	paths, err := filepath.Glob(filepath.Join(episodeDir, "*.png"))
+	if err != nil {
+		essentials.Die(err)
+	}
+	if len(paths) == 0 {
+		essentials.Die("no PNG frames in", episodeDir)
+	}
+	sort.Strings(paths)
+
+	if outPath == "" {
+		outPath = "saliency.gif"
+	}
+	var anim gif.GIF
+	state := policy.Start(1)
+	for i, path := range paths {
+		frame, err := treepolicy.LoadFrame(path)
+		if err != nil {
+			essentials.Die(err)
+		}
+		rgb, err := s.Grid.ImageRGB(frame)
+		if err != nil {
+			essentials.Die(err)
+		}
+		in := s.Grid.Features(rgb)
+		heatmap := treepolicy.Heatmap(s.Grid, compute(state, in), frame)
+		state = policy.Step(state, s.vector(in)).State()
+
+		paletted := image.NewPaletted(heatmap.Bounds(), palette.Plan9)
+		draw.Draw(paletted, paletted.Bounds(), heatmap, image.ZP, draw.Src)
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, delay)
+		log.Printf("frame %d/%d", i+1, len(paths))
+	}
+	if err := saveGIF(outPath, &anim); err != nil {
+		essentials.Die(err)
+	}
+}
+
+func saveGIF(path string, anim *gif.GIF) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return essentials.AddCtx("save GIF", err)
+	}
+	defer f.Close()
+	return essentials.AddCtx("save GIF", gif.EncodeAll(f, anim))
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+	"github.com/unixpickle/rl-agents/treepolicy"
+)
+
+// A Saliency computes saliency maps for a recurrent policy
+// with a Bernoulli action space, like the conv agents.
+//
+// Saliency is computed for the preprocessed input at one
+// timestep, given the state from the previous timesteps.
+type Saliency struct {
+	Policy  anyrnn.Block
+	Creator anyvec.Creator
+	Grid    *treepolicy.Grid
+}
+
+// Gradient computes the magnitude of the gradient of the
+// greedy action's log-probability with respect to each
+// input pixel.
+func (s *Saliency) Gradient(state anyrnn.State, in []float64) []float64 {
+	res := s.Policy.Step(state, s.vector(in))
+	out := treepolicy.VecFloats(res.Output())
+
+	// For a Bernoulli with logit x, the derivative of the
+	// log-probability of action a is a - sigmoid(x).
+	upstream := make([]float64, len(out))
+	for i, x := range out {
+		var a float64
+		if x > 0 {
+			a = 1
+		}
+		upstream[i] = a - sigmoid(x)
+	}
+	inGrad, _ := res.Propagate(s.vector(upstream), nil, anydiff.NewGrad())
+
+	grad := treepolicy.VecFloats(inGrad)
+	for i, x := range grad {
+		grad[i] = math.Abs(x)
+	}
+	return grad
+}
+
+// Perturbation computes how much the action probabilities
+// change when a Gaussian blob of the input is blurred.
+//
+// The blob has the given radius, in preprocessed pixels.
+// Blobs are centered every stride pixels, and each blob's
+// score fills the stride-by-stride block around it.
+func (s *Saliency) Perturbation(state anyrnn.State, in []float64,
+	radius, stride int) []float64 {
+	cols, rows := s.Grid.Cols(), s.Grid.Rows()
+	baseProbs := s.probs(state, in)
+	blurred := boxBlur(in, cols, rows, radius)
+
+	res := make([]float64, len(in))
+	perturbed := make([]float64, len(in))
+	sigma2 := 2 * float64(radius*radius)
+	for cy := stride / 2; cy < rows+stride/2; cy += stride {
+		for cx := stride / 2; cx < cols+stride/2; cx += stride {
+			for y := 0; y < rows; y++ {
+				for x := 0; x < cols; x++ {
+					dx, dy := float64(x-cx), float64(y-cy)
+					mask := math.Exp(-(dx*dx + dy*dy) / sigma2)
+					i := y*cols + x
+					perturbed[i] = in[i]*(1-mask) + blurred[i]*mask
+				}
+			}
+			var score float64
+			for i, p := range s.probs(state, perturbed) {
+				score += 0.5 * (p - baseProbs[i]) * (p - baseProbs[i])
+			}
+			for y := cy - stride/2; y < cy-stride/2+stride && y < rows; y++ {
+				for x := cx - stride/2; x < cx-stride/2+stride && x < cols; x++ {
+					res[y*cols+x] = score
+				}
+			}
+		}
+	}
+	return res
+}
+
+func (s *Saliency) probs(state anyrnn.State, in []float64) []float64 {
+	out := treepolicy.VecFloats(s.Policy.Step(state, s.vector(in)).Output())
+	for i, x := range out {
+		out[i] = sigmoid(x)
+	}
+	return out
+}
+
+func (s *Saliency) vector(data []float64) anyvec.Vector {
+	return s.Creator.MakeVectorData(s.Creator.MakeNumericList(data))
+}
+
+// boxBlur averages each pixel with its neighbors within
+// the radius.
+func boxBlur(in []float64, cols, rows, radius int) []float64 {
+	res := make([]float64, len(in))
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			var sum float64
+			var count int
+			for by := y - radius; by <= y+radius; by++ {
+				for bx := x - radius; bx <= x+radius; bx++ {
+					if bx >= 0 && by >= 0 && bx < cols && by < rows {
+						sum += in[by*cols+bx]
+						count++
+					}
+				}
+			}
+			res[y*cols+x] = sum / float64(count)
+		}
+	}
+	return res
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
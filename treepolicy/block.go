@@ -0,0 +1,104 @@
+package treepolicy
+
+import (
+	"math"
+
+	"github.com/unixpickle/anydiff"
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec"
+)
+
+// minBlockProb is the smallest probability a Block puts
+// on an action, so that greedy forests (with an Epsilon of
+// 0) do not produce -Inf log-probabilities.
+const minBlockProb = 1e-10
+
+// A Block is an anyrnn.Block which applies a Compiled
+// policy to a batch of feature vectors at every timestep.
+//
+// The outputs are action log-probabilities, so a Block can
+// be used with an anyrl.RNNRoller and an anyrl.Softmax
+// action space to step many environments as one batch.
+// Blocks are not differentiable.
+type Block struct {
+	Compiled *Compiled
+	Creator  anyvec.Creator
+}
+
+// Start returns a state for a batch of n sequences.
+func (b *Block) Start(n int) anyrnn.State {
+	present := make(anyrnn.PresentMap, n)
+	for i := range present {
+		present[i] = true
+	}
+	return blockState(present)
+}
+
+// Step applies the policy to one feature vector for each
+// present sequence.
+func (b *Block) Step(s anyrnn.State, in anyvec.Vector) anyrnn.Res {
+	var numPresent int
+	for _, p := range s.Present() {
+		if p {
+			numPresent++
+		}
+	}
+	out := make([]float64, numPresent*b.Compiled.NumActions)
+	b.Compiled.ApplyBatch(VecFloats(in), out)
+	for i, x := range out {
+		out[i] = math.Log(math.Max(x, minBlockProb))
+	}
+	return &blockRes{
+		state: s,
+		out:   b.Creator.MakeVectorData(b.Creator.MakeNumericList(out)),
+	}
+}
+
+type blockState anyrnn.PresentMap
+
+func (b blockState) Present() anyrnn.PresentMap {
+	return anyrnn.PresentMap(b)
+}
+
+func (b blockState) Reduce(p anyrnn.PresentMap) anyrnn.State {
+	return blockState(p)
+}
+
+type blockRes struct {
+	state anyrnn.State
+	out   anyvec.Vector
+}
+
+func (b *blockRes) State() anyrnn.State {
+	return b.state
+}
+
+func (b *blockRes) Output() anyvec.Vector {
+	return b.out
+}
+
+func (b *blockRes) Vars() anydiff.VarSet {
+	return anydiff.VarSet{}
+}
+
+func (b *blockRes) Propagate(u anyvec.Vector, s anyrnn.StateGrad,
+	g anydiff.Grad) (anyvec.Vector, anyrnn.StateGrad) {
+	panic("treepolicy: Block is not differentiable")
+}
+
+// VecFloats copies the components of a float32 or float64
+// vector into a new slice.
+func VecFloats(v anyvec.Vector) []float64 {
+	switch data := v.Data().(type) {
+	case []float64:
+		return append([]float64{}, data...)
+	case []float32:
+		res := make([]float64, len(data))
+		for i, x := range data {
+			res[i] = float64(x)
+		}
+		return res
+	default:
+		panic("unsupported numeric type")
+	}
+}
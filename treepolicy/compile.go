@@ -0,0 +1,165 @@
+package treepolicy
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/treeagent"
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+// A Compiled policy stores a tree or forest in flat arrays
+// so that it can be evaluated without allocations or map
+// lookups.
+type Compiled struct {
+	NumActions int
+	NumTrees   int
+	Epsilon    float64
+
+	// Roots stores the index of each tree's root node.
+	Roots []int32
+
+	// Nodes stores every node of every tree.
+	Nodes []CompiledNode
+
+	// Dists stores the leaf distributions, NumActions
+	// values per leaf.
+	Dists []float64
+}
+
+// A CompiledNode is a split or a leaf.
+//
+// For splits, inputs go to the LessEqual node if their
+// Feature is at most Threshold, or to the Greater node
+// otherwise.
+// For leaves, Feature is -1 and LessEqual is the offset of
+// the leaf's distribution in Dists.
+type CompiledNode struct {
+	Feature   int32
+	LessEqual int32
+	Greater   int32
+	Threshold float64
+}
+
+// Compile compiles a policy's classifier.
+//
+// The classifier must be a tree or forest with numeric
+// splits on integer features and integer classes, like
+// the ones trained by the tree agents.
+func Compile(policy *treeagent.Policy) (*Compiled, error) {
+	trees, err := Trees(policy.Classifier)
+	if err != nil {
+		return nil, err
+	}
+	res := &Compiled{
+		NumActions: policy.NumActions,
+		NumTrees:   len(trees),
+		Epsilon:    policy.Epsilon,
+	}
+	for _, tree := range trees {
+		root, err := res.addNode(tree)
+		if err != nil {
+			return nil, err
+		}
+		res.Roots = append(res.Roots, root)
+	}
+	return res, nil
+}
+
+func (c *Compiled) addNode(t *idtrees.Tree) (int32, error) {
+	idx := int32(len(c.Nodes))
+	c.Nodes = append(c.Nodes, CompiledNode{})
+	if t.ValSplit != nil {
+		return 0, fmt.Errorf("compile: value splits are not supported")
+	}
+	if t.NumSplit == nil {
+		offset := len(c.Dists)
+		c.Dists = append(c.Dists, make([]float64, c.NumActions)...)
+		for class, prob := range t.Classification {
+			action, ok := class.(int)
+			if !ok || action < 0 || action >= c.NumActions {
+				return 0, fmt.Errorf("compile: class %v is not an action index", class)
+			}
+			c.Dists[offset+action] = prob
+		}
+		c.Nodes[idx] = CompiledNode{Feature: -1, LessEqual: int32(offset)}
+		return idx, nil
+	}
+
+	feature, ok := t.Attr.(int)
+	if !ok {
+		return 0, fmt.Errorf("compile: unsupported attribute type: %T", t.Attr)
+	}
+	thresh, err := numericValue(t.NumSplit.Threshold)
+	if err != nil {
+		return 0, fmt.Errorf("compile: %s", err)
+	}
+	lessEqual, err := c.addNode(t.NumSplit.LessEqual)
+	if err != nil {
+		return 0, err
+	}
+	greater, err := c.addNode(t.NumSplit.Greater)
+	if err != nil {
+		return 0, err
+	}
+	c.Nodes[idx] = CompiledNode{
+		Feature:   int32(feature),
+		LessEqual: lessEqual,
+		Greater:   greater,
+		Threshold: thresh,
+	}
+	return idx, nil
+}
+
+// Apply computes the action probabilities for one input.
+func (c *Compiled) Apply(features []float64) []float64 {
+	res := make([]float64, c.NumActions)
+	c.ApplyBatch(features, res)
+	return res
+}
+
+// ApplyBatch computes action probabilities for a batch of
+// inputs, e.g. one per environment.
+//
+// The inputs are packed one after another in features,
+// and the action probabilities are written to out in the
+// same way.
+// Like treeagent.Policy, the leaf distributions are
+// averaged over the trees and mixed with a uniform
+// distribution according to Epsilon.
+func (c *Compiled) ApplyBatch(features, out []float64) {
+	numInputs := len(out) / c.NumActions
+	if numInputs == 0 {
+		return
+	}
+	numFeatures := len(features) / numInputs
+	for i := range out {
+		out[i] = 0
+	}
+
+	// Evaluate one tree at a time so that its nodes stay
+	// in the cache for the whole batch.
+	for _, root := range c.Roots {
+		for i := 0; i < numInputs; i++ {
+			input := features[i*numFeatures : (i+1)*numFeatures]
+			node := &c.Nodes[root]
+			for node.Feature >= 0 {
+				if input[node.Feature] <= node.Threshold {
+					node = &c.Nodes[node.LessEqual]
+				} else {
+					node = &c.Nodes[node.Greater]
+				}
+			}
+			dist := c.Dists[node.LessEqual : int(node.LessEqual)+c.NumActions]
+			outRow := out[i*c.NumActions : (i+1)*c.NumActions]
+			for j, prob := range dist {
+				outRow[j] += prob
+			}
+		}
+	}
+
+	scale := (1 - c.Epsilon) / float64(c.NumTrees)
+	uniform := c.Epsilon / float64(c.NumActions)
+	for i, x := range out {
+		out[i] = x*scale + uniform
+	}
+}
@@ -0,0 +1,177 @@
+package treepolicy
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/anynet/anyrnn"
+	"github.com/unixpickle/anyvec/anyvec64"
+	"github.com/unixpickle/treeagent"
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+const (
+	testNumFeatures = 150 * 38
+	testNumActions  = 3
+)
+
+func TestCompiled(t *testing.T) {
+	for _, numTrees := range []int{1, 5} {
+		policy := testPolicy(numTrees, 4)
+		compiled, err := Compile(policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var batch, expected []float64
+		for i := 0; i < 10; i++ {
+			input := testInput()
+			batch = append(batch, input...)
+			dist := policy.Classifier.(idtrees.Forest).Classify(featureMap(input))
+			for action := 0; action < testNumActions; action++ {
+				expected = append(expected,
+					dist[action]*(1-policy.Epsilon)+policy.Epsilon/testNumActions)
+			}
+		}
+
+		actual := make([]float64, len(expected))
+		compiled.ApplyBatch(batch, actual)
+		for i, x := range expected {
+			if math.Abs(actual[i]-x) > 1e-8 {
+				t.Fatalf("%d trees: output %d: expected %v but got %v", numTrees, i, x,
+					actual[i])
+			}
+		}
+	}
+}
+
+func TestCompiledTree(t *testing.T) {
+	tree := testTree(6)
+	policy := &treeagent.Policy{Classifier: tree, NumActions: testNumActions}
+	compiled, err := Compile(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		input := testInput()
+		expected := tree.Classify(featureMap(input))
+		actual := compiled.Apply(input)
+		for action, x := range actual {
+			if math.Abs(x-expected[action]) > 1e-8 {
+				t.Fatalf("input %d: expected %v but got %v", i, expected, actual)
+			}
+		}
+	}
+}
+
+func TestBlock(t *testing.T) {
+	compiled, err := Compile(testPolicy(3, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &Block{Compiled: compiled, Creator: anyvec64.DefaultCreator{}}
+
+	// The second sequence has ended, so only two inputs
+	// are passed in.
+	state := block.Start(3).Reduce(anyrnn.PresentMap{true, false, true})
+	inputs := [][]float64{testInput(), testInput()}
+	in := anyvec64.MakeVectorData(append(append([]float64{}, inputs[0]...), inputs[1]...))
+	res := block.Step(state, in)
+
+	out := res.Output().Data().([]float64)
+	if len(out) != 2*testNumActions {
+		t.Fatalf("expected %d outputs but got %d", 2*testNumActions, len(out))
+	}
+	for i, input := range inputs {
+		for action, prob := range compiled.Apply(input) {
+			actual := math.Exp(out[i*testNumActions+action])
+			if math.Abs(actual-prob) > 1e-8 {
+				t.Errorf("input %d action %d: expected %v but got %v", i, action, prob,
+					actual)
+			}
+		}
+	}
+}
+
+func TestBlockGreedy(t *testing.T) {
+	leaf := &idtrees.Tree{Classification: map[idtrees.Class]float64{1: 1}}
+	policy := &treeagent.Policy{Classifier: leaf, NumActions: testNumActions}
+	compiled, err := Compile(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &Block{Compiled: compiled, Creator: anyvec64.DefaultCreator{}}
+	res := block.Step(block.Start(1), anyvec64.MakeVectorData(testInput()))
+	for i, x := range res.Output().Data().([]float64) {
+		if math.IsInf(x, 0) || math.IsNaN(x) {
+			t.Errorf("action %d: log-probability is %f", i, x)
+		}
+	}
+}
+
+func BenchmarkForest(b *testing.B) {
+	forest := testPolicy(20, 4).Classifier.(idtrees.Forest)
+	input := featureMap(testInput())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		forest.Classify(input)
+	}
+}
+
+func BenchmarkCompiled(b *testing.B) {
+	compiled, err := Compile(testPolicy(20, 4))
+	if err != nil {
+		b.Fatal(err)
+	}
+	input := testInput()
+	out := make([]float64, testNumActions)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compiled.ApplyBatch(input, out)
+	}
+}
+
+type featureMap []float64
+
+func (f featureMap) Attr(attr idtrees.Attr) idtrees.Val {
+	return f[attr.(int)]
+}
+
+func testPolicy(numTrees, depth int) *treeagent.Policy {
+	var forest idtrees.Forest
+	for i := 0; i < numTrees; i++ {
+		forest = append(forest, testTree(depth))
+	}
+	return &treeagent.Policy{
+		Classifier: forest,
+		NumActions: testNumActions,
+		Epsilon:    0.05,
+	}
+}
+
+func testTree(depth int) *idtrees.Tree {
+	if depth == 0 {
+		dist := map[idtrees.Class]float64{}
+		for i := 0; i < testNumActions; i++ {
+			dist[i] = rand.Float64()
+		}
+		return &idtrees.Tree{Classification: dist}
+	}
+	return &idtrees.Tree{
+		Attr: rand.Intn(testNumFeatures),
+		NumSplit: &idtrees.NumSplit{
+			Threshold: rand.Float64() * 255,
+			LessEqual: testTree(depth - 1),
+			Greater:   testTree(depth - 1),
+		},
+	}
+}
+
+func testInput() []float64 {
+	res := make([]float64, testNumFeatures)
+	for i := range res {
+		res[i] = float64(rand.Intn(256))
+	}
+	return res
+}
@@ -0,0 +1,269 @@
+package treepolicy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+// Trees extracts the trees from a tree or forest
+// classifier.
+func Trees(classifier interface{}) ([]*idtrees.Tree, error) {
+	switch c := classifier.(type) {
+	case *idtrees.Tree:
+		return []*idtrees.Tree{c}, nil
+	case idtrees.Forest:
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unsupported classifier type: %T", classifier)
+	}
+}
+
+// An Exporter renders trees in human- and
+// machine-readable formats.
+type Exporter struct {
+	// Grid, if non-nil, is used to name attributes after
+	// the pixels they came from.
+	Grid *Grid
+
+	// Features, if non-nil, is used to name attributes
+	// instead of Grid.
+	Features FeatureExtractor
+
+	// ActionNames, if non-nil, names the classes.
+	ActionNames []string
+}
+
+// WriteRules writes the trees as indented if/else rules.
+func (e *Exporter) WriteRules(w io.Writer, trees []*idtrees.Tree) error {
+	bw := bufio.NewWriter(w)
+	for i, tree := range trees {
+		fmt.Fprintf(bw, "tree %d:\n", i)
+		e.writeRules(bw, tree, 1)
+	}
+	return bw.Flush()
+}
+
+func (e *Exporter) writeRules(w io.Writer, t *idtrees.Tree, depth int) {
+	indent := strings.Repeat("  ", depth)
+	attr := e.attrName(t.Attr)
+	switch {
+	case t.NumSplit != nil:
+		fmt.Fprintf(w, "%sif %s <= %v:\n", indent, attr, t.NumSplit.Threshold)
+		e.writeRules(w, t.NumSplit.LessEqual, depth+1)
+		fmt.Fprintf(w, "%selse:\n", indent)
+		e.writeRules(w, t.NumSplit.Greater, depth+1)
+	case t.ValSplit != nil:
+		for _, val := range sortedVals(t.ValSplit) {
+			fmt.Fprintf(w, "%sif %s == %v:\n", indent, attr, val)
+			e.writeRules(w, t.ValSplit[val], depth+1)
+		}
+	default:
+		fmt.Fprintf(w, "%sreturn %s\n", indent, e.distString(t.Classification, ", "))
+	}
+}
+
+// WriteDOT writes the trees as a Graphviz graph, with one
+// cluster per tree.
+func (e *Exporter) WriteDOT(w io.Writer, trees []*idtrees.Tree) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph policy {")
+	fmt.Fprintln(bw, "  node [shape=box, fontname=\"Helvetica\"];")
+	var nextID int
+	for i, tree := range trees {
+		fmt.Fprintf(bw, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(bw, "    label=\"tree %d\";\n", i)
+		e.writeDOTNode(bw, tree, &nextID)
+		fmt.Fprintln(bw, "  }")
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func (e *Exporter) writeDOTNode(w io.Writer, t *idtrees.Tree, nextID *int) int {
+	id := *nextID
+	*nextID++
+	switch {
+	case t.NumSplit != nil:
+		fmt.Fprintf(w, "    n%d [label=%q];\n", id,
+			fmt.Sprintf("%s <= %v", e.attrName(t.Attr), t.NumSplit.Threshold))
+		left := e.writeDOTNode(w, t.NumSplit.LessEqual, nextID)
+		right := e.writeDOTNode(w, t.NumSplit.Greater, nextID)
+		fmt.Fprintf(w, "    n%d -> n%d [label=\"yes\"];\n", id, left)
+		fmt.Fprintf(w, "    n%d -> n%d [label=\"no\"];\n", id, right)
+	case t.ValSplit != nil:
+		fmt.Fprintf(w, "    n%d [label=%q];\n", id, e.attrName(t.Attr))
+		for _, val := range sortedVals(t.ValSplit) {
+			child := e.writeDOTNode(w, t.ValSplit[val], nextID)
+			fmt.Fprintf(w, "    n%d -> n%d [label=%q];\n", id, child, fmt.Sprintf("= %v", val))
+		}
+	default:
+		fmt.Fprintf(w, "    n%d [label=%q, style=filled, fillcolor=lightgrey];\n", id,
+			e.distString(t.Classification, "\n"))
+	}
+	return id
+}
+
+// WriteGo writes a standalone Go source file implementing
+// the trees.
+//
+// The generated function takes the feature vector produced
+// by the agent's preprocessing and returns the policy's
+// action probabilities: the classifier's output, averaged
+// over the trees, mixed with a uniform distribution
+// according to epsilon (like treeagent.Policy).
+// The epsilon is exported as a constant named after the
+// function.
+// Classes must be action indices in [0, numActions).
+func (e *Exporter) WriteGo(w io.Writer, trees []*idtrees.Tree, pkg, funcName string,
+	numActions int, epsilon float64) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "// Code generated by treetool; DO NOT EDIT.")
+	fmt.Fprintln(bw)
+	fmt.Fprintf(bw, "package %s\n\n", pkg)
+	fmt.Fprintf(bw, "// %sEpsilon is the probability of a uniformly random\n", funcName)
+	fmt.Fprintf(bw, "// action in %s.\n", funcName)
+	fmt.Fprintf(bw, "const %sEpsilon = %v\n\n", funcName, epsilon)
+	fmt.Fprintf(bw, "// %s computes action probabilities from a preprocessed\n", funcName)
+	fmt.Fprintf(bw, "// frame by averaging %d decision tree(s) and mixing in\n", len(trees))
+	fmt.Fprintf(bw, "// %sEpsilon of a uniform distribution.\n", funcName)
+	if e.ActionNames != nil {
+		fmt.Fprintf(bw, "//\n// The actions are: %s.\n", strings.Join(e.ActionNames, ", "))
+	}
+	fmt.Fprintf(bw, "func %s(features []float64) []float64 {\n", funcName)
+	fmt.Fprintf(bw, "\tres := make([]float64, %d)\n", numActions)
+	for i := range trees {
+		fmt.Fprintf(bw, "\t%sTree%d(features, res)\n", funcName, i)
+	}
+	fmt.Fprintln(bw, "\tfor i, x := range res {")
+	fmt.Fprintf(bw, "\t\tres[i] = x*(1-%sEpsilon)/%d + %sEpsilon/%d\n", funcName, len(trees),
+		funcName, numActions)
+	fmt.Fprintln(bw, "\t}")
+	fmt.Fprintln(bw, "\treturn res")
+	fmt.Fprintln(bw, "}")
+
+	for i, tree := range trees {
+		fmt.Fprintf(bw, "\nfunc %sTree%d(f, res []float64) {\n", funcName, i)
+		if err := e.writeGoNode(bw, tree, 1, numActions); err != nil {
+			return err
+		}
+		fmt.Fprintln(bw, "}")
+	}
+	return bw.Flush()
+}
+
+func (e *Exporter) writeGoNode(w io.Writer, t *idtrees.Tree, depth, numActions int) error {
+	indent := strings.Repeat("\t", depth)
+	switch {
+	case t.NumSplit != nil:
+		idx, ok := t.Attr.(int)
+		if !ok {
+			return fmt.Errorf("unsupported attribute type: %T", t.Attr)
+		}
+		thresh, err := numericValue(t.NumSplit.Threshold)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%sif f[%d] <= %v { // %s\n", indent, idx, thresh, e.attrName(t.Attr))
+		if err := e.writeGoNode(w, t.NumSplit.LessEqual, depth+1, numActions); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s} else {\n", indent)
+		if err := e.writeGoNode(w, t.NumSplit.Greater, depth+1, numActions); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s}\n", indent)
+	case t.ValSplit != nil:
+		idx, ok := t.Attr.(int)
+		if !ok {
+			return fmt.Errorf("unsupported attribute type: %T", t.Attr)
+		}
+		fmt.Fprintf(w, "%sswitch f[%d] { // %s\n", indent, idx, e.attrName(t.Attr))
+		for _, val := range sortedVals(t.ValSplit) {
+			num, err := numericValue(val)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%scase %v:\n", indent, num)
+			if err := e.writeGoNode(w, t.ValSplit[val], depth+1, numActions); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(w, "%s}\n", indent)
+	default:
+		for _, class := range sortedClasses(t.Classification) {
+			action, ok := class.(int)
+			if !ok || action < 0 || action >= numActions {
+				return fmt.Errorf("class %v is not an action index", class)
+			}
+			if prob := t.Classification[class]; prob != 0 {
+				fmt.Fprintf(w, "%sres[%d] += %v\n", indent, action, prob)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) attrName(attr idtrees.Attr) string {
+	if idx, ok := attr.(int); ok && e.Features != nil && idx < e.Features.NumFeatures() {
+		return e.Features.FeatureName(idx)
+	}
+	return e.Grid.AttrName(attr)
+}
+
+func (e *Exporter) distString(dist map[idtrees.Class]float64, sep string) string {
+	var parts []string
+	for _, class := range sortedClasses(dist) {
+		name := fmt.Sprint(class)
+		if idx, ok := class.(int); ok && idx >= 0 && idx < len(e.ActionNames) {
+			name = e.ActionNames[idx]
+		}
+		parts = append(parts, fmt.Sprintf("%s: %.3f", name, dist[class]))
+	}
+	return strings.Join(parts, sep)
+}
+
+func sortedClasses(dist map[idtrees.Class]float64) []idtrees.Class {
+	var res []idtrees.Class
+	for class := range dist {
+		res = append(res, class)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return fmt.Sprint(res[i]) < fmt.Sprint(res[j])
+	})
+	return res
+}
+
+func sortedVals(split idtrees.ValSplit) []idtrees.Val {
+	var res []idtrees.Val
+	for val := range split {
+		res = append(res, val)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return fmt.Sprint(res[i]) < fmt.Sprint(res[j])
+	})
+	return res
+}
+
+// numericValue converts an attribute value or threshold
+// to a float64.
+func numericValue(val interface{}) (float64, error) {
+	switch val := val.(type) {
+	case float64:
+		return val, nil
+	case float32:
+		return float64(val), nil
+	case int:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case uint8:
+		return float64(val), nil
+	default:
+		return 0, fmt.Errorf("unsupported value type: %T", val)
+	}
+}
@@ -0,0 +1,107 @@
+package treepolicy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+const testGoMain = `package main
+
+import (
+	"encoding/json"
+	"os"
+)
+
+func main() {
+	var inputs [][]float64
+	if err := json.NewDecoder(os.Stdin).Decode(&inputs); err != nil {
+		panic(err)
+	}
+	var outputs [][]float64
+	for _, input := range inputs {
+		outputs = append(outputs, Policy(input))
+	}
+	json.NewEncoder(os.Stdout).Encode(outputs)
+}
+`
+
+func TestWriteGo(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	policy := testPolicy(3, 4)
+	compiled, err := Compile(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees, err := Trees(policy.Classifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "treepolicy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var source bytes.Buffer
+	err = (&Exporter{}).WriteGo(&source, trees, "main", "Policy", policy.NumActions,
+		policy.Epsilon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":    "module gentest\n",
+		"policy.go": source.String(),
+		"main.go":   testGoMain,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var inputs [][]float64
+	for i := 0; i < 10; i++ {
+		inputs = append(inputs, testInput())
+	}
+	inputData, err := json.Marshal(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GO111MODULE=on")
+	cmd.Stdin = bytes.NewReader(inputData)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			t.Fatalf("%s: %s", err, exitErr.Stderr)
+		}
+		t.Fatal(err)
+	}
+	var outputs [][]float64
+	if err := json.Unmarshal(output, &outputs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(outputs) != len(inputs) {
+		t.Fatalf("expected %d outputs but got %d", len(inputs), len(outputs))
+	}
+	for i, input := range inputs {
+		expected := compiled.Apply(input)
+		for action, x := range expected {
+			if math.Abs(outputs[i][action]-x) > 1e-8 {
+				t.Fatalf("input %d: expected %v but got %v", i, expected, outputs[i])
+			}
+		}
+	}
+}
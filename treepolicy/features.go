@@ -0,0 +1,318 @@
+package treepolicy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/essentials"
+)
+
+// DefaultPoolSizes are the region sizes, in pixels, used by
+// the "pool" feature spec.
+var DefaultPoolSizes = []int{16, 32, 64}
+
+// A FeatureExtractor turns RGB frames into features for a
+// tree policy.
+//
+// Features are integers in [0, 255], since the tree agents
+// store their inputs in compressed uint8 tapes.
+//
+// Extractors may keep state between frames, so each
+// environment needs its own extractor.
+type FeatureExtractor interface {
+	// NumFeatures returns the number of features produced
+	// for each frame.
+	NumFeatures() int
+
+	// FeatureName describes a feature for humans.
+	FeatureName(idx int) string
+
+	// Reset is called at the start of each episode.
+	Reset()
+
+	// Extract computes the features for the next frame of
+	// an episode.
+	Extract(rgb []uint8) []float64
+}
+
+// ParseFeatures creates a FeatureExtractor from a comma
+// separated list of extractor names.
+//
+// The supported names are:
+//
+//	pixels       downsampled pixels (PixelFeatures)
+//	diff         frame differences (DiffFeatures)
+//	edges        edge strengths (EdgeFeatures)
+//	pool         average-pooled regions (PoolFeatures)
+//	pool:A:B:... pooled regions of sizes A, B, ...
+func ParseFeatures(spec string, g *Grid) (FeatureExtractor, error) {
+	var res FeatureList
+	for _, name := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(name), ":")
+		switch parts[0] {
+		case "pixels":
+			res = append(res, &PixelFeatures{Grid: g})
+		case "diff":
+			res = append(res, &DiffFeatures{Grid: g})
+		case "edges":
+			res = append(res, &EdgeFeatures{Grid: g})
+		case "pool":
+			sizes := DefaultPoolSizes
+			if len(parts) > 1 {
+				sizes = nil
+				for _, s := range parts[1:] {
+					size, err := strconv.Atoi(s)
+					if err != nil || size <= 0 {
+						return nil, fmt.Errorf("parse features: bad pool size: %s", s)
+					}
+					sizes = append(sizes, size)
+				}
+			}
+			res = append(res, &PoolFeatures{Grid: g, Sizes: sizes})
+		default:
+			return nil, fmt.Errorf("parse features: unknown extractor: %s", name)
+		}
+	}
+	if len(res) == 1 {
+		return res[0], nil
+	} else if len(res) == 0 {
+		return nil, errors.New("parse features: no extractors")
+	}
+	return res, nil
+}
+
+// GridValues sums per-feature values (e.g. importances)
+// into the grid cells the features were computed from.
+//
+// Features which do not correspond to a single cell, such
+// as pooled regions, are left out.
+func GridValues(f FeatureExtractor, g *Grid, values []float64) []float64 {
+	res := make([]float64, g.NumFeatures())
+	for i, x := range values {
+		if cell, ok := gridCell(f, i); ok && cell < len(res) {
+			res[cell] += x
+		}
+	}
+	return res
+}
+
+func gridCell(f FeatureExtractor, idx int) (int, bool) {
+	switch f := f.(type) {
+	case *PixelFeatures, *DiffFeatures, *EdgeFeatures:
+		return idx, true
+	case FeatureList:
+		for _, x := range f {
+			if idx < x.NumFeatures() {
+				return gridCell(x, idx)
+			}
+			idx -= x.NumFeatures()
+		}
+	}
+	return 0, false
+}
+
+// PixelFeatures are the downsampled greyscale pixels of
+// each frame.
+type PixelFeatures struct {
+	Grid *Grid
+}
+
+func (p *PixelFeatures) NumFeatures() int {
+	return p.Grid.NumFeatures()
+}
+
+func (p *PixelFeatures) FeatureName(idx int) string {
+	return p.Grid.AttrName(idx)
+}
+
+func (p *PixelFeatures) Reset() {
+}
+
+func (p *PixelFeatures) Extract(rgb []uint8) []float64 {
+	return p.Grid.Features(rgb)
+}
+
+// DiffFeatures are the changes in the downsampled pixels
+// since the previous frame, mapped from [-255, 255] to
+// [0, 255].
+// There is no change on the first frame of an episode.
+type DiffFeatures struct {
+	Grid *Grid
+
+	last []float64
+}
+
+func (d *DiffFeatures) NumFeatures() int {
+	return d.Grid.NumFeatures()
+}
+
+func (d *DiffFeatures) FeatureName(idx int) string {
+	x, y := d.Grid.Pixel(idx)
+	return fmt.Sprintf("diff(x=%d, y=%d)", x, y)
+}
+
+func (d *DiffFeatures) Reset() {
+	d.last = nil
+}
+
+func (d *DiffFeatures) Extract(rgb []uint8) []float64 {
+	pixels := d.Grid.Features(rgb)
+	res := make([]float64, len(pixels))
+	for i, x := range pixels {
+		var diff float64
+		if d.last != nil {
+			diff = x - d.last[i]
+		}
+		res[i] = essentials.Round((diff + 255) / 2)
+	}
+	d.last = pixels
+	return res
+}
+
+// EdgeFeatures measure the edge strength at each
+// downsampled pixel, as the mean absolute difference to
+// the pixels right of and below it.
+type EdgeFeatures struct {
+	Grid *Grid
+}
+
+func (e *EdgeFeatures) NumFeatures() int {
+	return e.Grid.NumFeatures()
+}
+
+func (e *EdgeFeatures) FeatureName(idx int) string {
+	x, y := e.Grid.Pixel(idx)
+	return fmt.Sprintf("edge(x=%d, y=%d)", x, y)
+}
+
+func (e *EdgeFeatures) Reset() {
+}
+
+func (e *EdgeFeatures) Extract(rgb []uint8) []float64 {
+	pixels := e.Grid.Features(rgb)
+	cols, rows := e.Grid.Cols(), e.Grid.Rows()
+	res := make([]float64, len(pixels))
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			idx := row*cols + col
+			var sum float64
+			if col+1 < cols {
+				sum += math.Abs(pixels[idx+1] - pixels[idx])
+			}
+			if row+1 < rows {
+				sum += math.Abs(pixels[idx+cols] - pixels[idx])
+			}
+			res[idx] = essentials.Round(sum / 2)
+		}
+	}
+	return res
+}
+
+// PoolFeatures are the mean greyscale values of square
+// regions of the frame, tiled at several sizes.
+// This gives trees coarse context which would otherwise
+// take many splits to detect.
+type PoolFeatures struct {
+	Grid *Grid
+
+	// Sizes are the region sizes, in pixels.
+	Sizes []int
+}
+
+func (p *PoolFeatures) NumFeatures() int {
+	var res int
+	for _, size := range p.Sizes {
+		cols, rows := p.regions(size)
+		res += cols * rows
+	}
+	return res
+}
+
+func (p *PoolFeatures) FeatureName(idx int) string {
+	for _, size := range p.Sizes {
+		cols, rows := p.regions(size)
+		if idx < cols*rows {
+			return fmt.Sprintf("pool%d(x=%d, y=%d)", size, (idx%cols)*size,
+				(idx/cols)*size)
+		}
+		idx -= cols * rows
+	}
+	return fmt.Sprintf("pool[%d]", idx)
+}
+
+func (p *PoolFeatures) Reset() {
+}
+
+func (p *PoolFeatures) Extract(rgb []uint8) []float64 {
+	res := make([]float64, 0, p.NumFeatures())
+	w, h := p.Grid.FrameWidth, p.Grid.FrameHeight
+	for _, size := range p.Sizes {
+		cols, rows := p.regions(size)
+		sums := make([]float64, cols*rows)
+		for y := 0; y < h; y++ {
+			rowOffset := (y / size) * cols
+			for x := 0; x < w; x++ {
+				idx := (y*w + x) * 3
+				sums[rowOffset+x/size] += float64(rgb[idx]) + float64(rgb[idx+1]) +
+					float64(rgb[idx+2])
+			}
+		}
+		for i, sum := range sums {
+			col, row := i%cols, i/cols
+			width := minInt(size, w-col*size)
+			height := minInt(size, h-row*size)
+			res = append(res, essentials.Round(sum/float64(3*width*height)))
+		}
+	}
+	return res
+}
+
+func (p *PoolFeatures) regions(size int) (cols, rows int) {
+	return (p.Grid.FrameWidth + size - 1) / size, (p.Grid.FrameHeight + size - 1) / size
+}
+
+// FeatureList concatenates the features of several
+// extractors.
+type FeatureList []FeatureExtractor
+
+func (f FeatureList) NumFeatures() int {
+	var res int
+	for _, x := range f {
+		res += x.NumFeatures()
+	}
+	return res
+}
+
+func (f FeatureList) FeatureName(idx int) string {
+	for _, x := range f {
+		if idx < x.NumFeatures() {
+			return x.FeatureName(idx)
+		}
+		idx -= x.NumFeatures()
+	}
+	return fmt.Sprintf("feature[%d]", idx)
+}
+
+func (f FeatureList) Reset() {
+	for _, x := range f {
+		x.Reset()
+	}
+}
+
+func (f FeatureList) Extract(rgb []uint8) []float64 {
+	res := make([]float64, 0, f.NumFeatures())
+	for _, x := range f {
+		res = append(res, x.Extract(rgb)...)
+	}
+	return res
+}
+
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
@@ -0,0 +1,30 @@
+package treepolicy
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFeatureExtractors(t *testing.T) {
+	grid := &Grid{FrameWidth: 600, FrameHeight: 150, Stride: 4}
+	features, err := ParseFeatures("pixels,diff,edges,pool,pool:7", grid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]uint8, grid.FrameWidth*grid.FrameHeight*3)
+	features.Reset()
+	for i := 0; i < 2; i++ {
+		for j := range frame {
+			frame[j] = uint8(rand.Intn(256))
+		}
+		res := features.Extract(frame)
+		if len(res) != features.NumFeatures() {
+			t.Fatalf("expected %d features but got %d", features.NumFeatures(), len(res))
+		}
+		for j, x := range res {
+			if x < 0 || x > 255 || x != float64(int(x)) {
+				t.Fatalf("feature %s out of range: %v", features.FeatureName(j), x)
+			}
+		}
+	}
+}
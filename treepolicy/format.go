@@ -0,0 +1,272 @@
+package treepolicy
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/treeagent"
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+// FormatVersion is the version of the save format written
+// by SavePolicy.
+const FormatVersion = 1
+
+func init() {
+	// Needed to read legacy policy files.
+	gob.Register(&idtrees.Tree{})
+	gob.Register(idtrees.Forest{})
+}
+
+// Metadata describes the game a policy was trained on.
+type Metadata struct {
+	// Spec is the muniverse environment name.
+	Spec string
+
+	FrameWidth  int
+	FrameHeight int
+	Stride      int
+
+	// Actions names the actions, by index.
+	Actions []string
+
+	// Features is the feature spec passed to
+	// ParseFeatures.
+	// If it is empty, the features are "pixels".
+	Features string `json:",omitempty"`
+}
+
+// GameMetadata creates metadata for a game.
+func GameMetadata(g *Game) *Metadata {
+	return &Metadata{
+		Spec:        g.Spec,
+		FrameWidth:  g.Grid.FrameWidth,
+		FrameHeight: g.Grid.FrameHeight,
+		Stride:      g.Grid.Stride,
+		Actions:     g.Actions,
+	}
+}
+
+// Game converts the metadata into a Game.
+func (m *Metadata) Game() *Game {
+	return &Game{
+		Spec: m.Spec,
+		Grid: Grid{
+			FrameWidth:  m.FrameWidth,
+			FrameHeight: m.FrameHeight,
+			Stride:      m.Stride,
+		},
+		Actions: m.Actions,
+	}
+}
+
+// FeatureSpec returns the feature spec, filling in the
+// default.
+func (m *Metadata) FeatureSpec() string {
+	if m.Features == "" {
+		return "pixels"
+	}
+	return m.Features
+}
+
+// FeatureExtractor creates a FeatureExtractor for the
+// policy's features.
+func (m *Metadata) FeatureExtractor() (FeatureExtractor, error) {
+	return ParseFeatures(m.FeatureSpec(), &m.Game().Grid)
+}
+
+// savedPolicy is the on-disk representation of a policy.
+//
+// Trees are stored in a format of their own rather than
+// with gob, so that files do not depend on the layout of
+// the idtrees types.
+type savedPolicy struct {
+	Version  int
+	Metadata *Metadata
+
+	NumActions int
+	Epsilon    float64
+
+	// Forest is false if the classifier is a single tree.
+	Forest bool
+	Trees  []*savedNode
+}
+
+// savedNode is a tree node.
+// Leaves have a Dist, which gives the probability of each
+// action.
+type savedNode struct {
+	Feature   int        `json:",omitempty"`
+	Threshold float64    `json:",omitempty"`
+	LessEqual *savedNode `json:",omitempty"`
+	Greater   *savedNode `json:",omitempty"`
+
+	Dist []float64 `json:",omitempty"`
+}
+
+// SavePolicy saves a policy and its metadata.
+//
+// The classifier must be a tree or forest with numeric
+// splits on integer features and integer classes.
+func SavePolicy(path string, policy *treeagent.Policy, meta *Metadata) error {
+	trees, err := Trees(policy.Classifier)
+	if err != nil {
+		return essentials.AddCtx("save policy", err)
+	}
+	_, isForest := policy.Classifier.(idtrees.Forest)
+	saved := &savedPolicy{
+		Version:    FormatVersion,
+		Metadata:   meta,
+		NumActions: policy.NumActions,
+		Epsilon:    policy.Epsilon,
+		Forest:     isForest,
+	}
+	for _, tree := range trees {
+		node, err := encodeTree(tree, policy.NumActions)
+		if err != nil {
+			return essentials.AddCtx("save policy", err)
+		}
+		saved.Trees = append(saved.Trees, node)
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return essentials.AddCtx("save policy", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return essentials.AddCtx("save policy", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return essentials.AddCtx("save policy", err)
+	}
+	return nil
+}
+
+// LoadPolicy loads a policy saved by SavePolicy.
+//
+// It can also load the gob files written by older versions
+// of the tree agents, in which case the metadata is nil.
+func LoadPolicy(path string) (*treeagent.Policy, *Metadata, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, essentials.AddCtx("load policy", err)
+	}
+	if len(data) == 0 || data[0] != '{' {
+		policy, err := loadLegacy(data)
+		if err != nil {
+			return nil, nil, essentials.AddCtx("load policy", err)
+		}
+		return policy, nil, nil
+	}
+
+	var saved savedPolicy
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, nil, essentials.AddCtx("load policy", err)
+	}
+	if saved.Version > FormatVersion {
+		return nil, nil, fmt.Errorf("load policy: unsupported version %d (max %d)",
+			saved.Version, FormatVersion)
+	}
+	policy := &treeagent.Policy{
+		NumActions: saved.NumActions,
+		Epsilon:    saved.Epsilon,
+	}
+	var forest idtrees.Forest
+	for _, node := range saved.Trees {
+		tree, err := decodeTree(node)
+		if err != nil {
+			return nil, nil, essentials.AddCtx("load policy", err)
+		}
+		forest = append(forest, tree)
+	}
+	if saved.Forest {
+		policy.Classifier = forest
+	} else if len(forest) == 1 {
+		policy.Classifier = forest[0]
+	} else {
+		return nil, nil, errors.New("load policy: expected exactly one tree")
+	}
+	return policy, saved.Metadata, nil
+}
+
+func loadLegacy(data []byte) (*treeagent.Policy, error) {
+	var res *treeagent.Policy
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func encodeTree(t *idtrees.Tree, numActions int) (*savedNode, error) {
+	if t.ValSplit != nil {
+		return nil, errors.New("value splits are not supported")
+	}
+	if t.NumSplit == nil {
+		dist := make([]float64, numActions)
+		for class, prob := range t.Classification {
+			idx, ok := class.(int)
+			if !ok || idx < 0 || idx >= numActions {
+				return nil, fmt.Errorf("class %v is not an action index", class)
+			}
+			dist[idx] = prob
+		}
+		return &savedNode{Dist: dist}, nil
+	}
+	feature, ok := t.Attr.(int)
+	if !ok {
+		return nil, fmt.Errorf("unsupported attribute type: %T", t.Attr)
+	}
+	thresh, err := numericValue(t.NumSplit.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	lessEqual, err := encodeTree(t.NumSplit.LessEqual, numActions)
+	if err != nil {
+		return nil, err
+	}
+	greater, err := encodeTree(t.NumSplit.Greater, numActions)
+	if err != nil {
+		return nil, err
+	}
+	return &savedNode{
+		Feature:   feature,
+		Threshold: thresh,
+		LessEqual: lessEqual,
+		Greater:   greater,
+	}, nil
+}
+
+func decodeTree(n *savedNode) (*idtrees.Tree, error) {
+	if n.Dist != nil {
+		dist := map[idtrees.Class]float64{}
+		for i, prob := range n.Dist {
+			dist[i] = prob
+		}
+		return &idtrees.Tree{Classification: dist}, nil
+	}
+	if n.LessEqual == nil || n.Greater == nil {
+		return nil, errors.New("split is missing a branch")
+	}
+	lessEqual, err := decodeTree(n.LessEqual)
+	if err != nil {
+		return nil, err
+	}
+	greater, err := decodeTree(n.Greater)
+	if err != nil {
+		return nil, err
+	}
+	return &idtrees.Tree{
+		Attr: n.Feature,
+		NumSplit: &idtrees.NumSplit{
+			Threshold: n.Threshold,
+			LessEqual: lessEqual,
+			Greater:   greater,
+		},
+	}, nil
+}
@@ -0,0 +1,110 @@
+// Package treepolicy provides tools for saving, running,
+// and inspecting treeagent policies trained on downsampled
+// game frames.
+package treepolicy
+
+import (
+	"fmt"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+// A Grid describes how a frame was downsampled into
+// features, so that features can be mapped back to
+// pixels.
+//
+// Features are stored row-major, taking every Stride-th
+// pixel of every Stride-th row, like the simplifyImage
+// methods of the tree agents.
+type Grid struct {
+	FrameWidth  int
+	FrameHeight int
+	Stride      int
+}
+
+// Cols returns the number of features per row.
+func (g *Grid) Cols() int {
+	return (g.FrameWidth + g.Stride - 1) / g.Stride
+}
+
+// Rows returns the number of rows of features.
+func (g *Grid) Rows() int {
+	return (g.FrameHeight + g.Stride - 1) / g.Stride
+}
+
+// NumFeatures returns the number of pixel features.
+func (g *Grid) NumFeatures() int {
+	return g.Cols() * g.Rows()
+}
+
+// Cell returns the grid coordinates of a feature.
+func (g *Grid) Cell(feature int) (col, row int) {
+	return feature % g.Cols(), feature / g.Cols()
+}
+
+// Pixel returns the frame coordinates of a feature.
+func (g *Grid) Pixel(feature int) (x, y int) {
+	col, row := g.Cell(feature)
+	return col * g.Stride, row * g.Stride
+}
+
+// AttrName describes a tree attribute in terms of the
+// pixel it was sampled from.
+//
+// Attributes past the end of the grid (e.g. from extra
+// feature extractors) are named by their index.
+func (g *Grid) AttrName(attr idtrees.Attr) string {
+	idx, ok := attr.(int)
+	if !ok || g == nil || idx >= g.NumFeatures() {
+		return fmt.Sprintf("feature[%v]", attr)
+	}
+	x, y := g.Pixel(idx)
+	return fmt.Sprintf("pixel(x=%d, y=%d)", x, y)
+}
+
+// A Game describes the frames and actions of a game
+// played by one of the tree agents.
+type Game struct {
+	Spec    string
+	Grid    Grid
+	Actions []string
+}
+
+// Games maps short names to the games played by the tree
+// agents in this repository.
+var Games = map[string]*Game{
+	"trex": {
+		Spec:    "TRex-v0",
+		Grid:    Grid{FrameWidth: 600, FrameHeight: 150, Stride: 4},
+		Actions: []string{"ArrowUp", "ArrowDown", "none"},
+	},
+	"twins": {
+		Spec:    "Twins-v0",
+		Grid:    Grid{FrameWidth: 320, FrameHeight: 480, Stride: 4},
+		Actions: []string{"ArrowLeft", "ArrowRight", "none"},
+	},
+	"knightower": {
+		Spec:    "Knightower-v0",
+		Grid:    Grid{FrameWidth: 320, FrameHeight: 480, Stride: 4},
+		Actions: []string{"ArrowLeft", "ArrowRight", "none"},
+	},
+}
+
+// Features downsamples an RGB frame the same way as the
+// tree agents, averaging the channels of every sampled
+// pixel.
+func (g *Grid) Features(rgb []uint8) []float64 {
+	res := make([]float64, 0, g.NumFeatures())
+	for y := 0; y < g.FrameHeight; y += g.Stride {
+		for x := 0; x < g.FrameWidth; x += g.Stride {
+			sourceIdx := (y*g.FrameWidth + x) * 3
+			var value float64
+			for d := 0; d < 3; d++ {
+				value += float64(rgb[sourceIdx+d])
+			}
+			res = append(res, essentials.Round(value/3))
+		}
+	}
+	return res
+}
@@ -0,0 +1,48 @@
+package treepolicy
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Heatmap draws per-feature values over a frame.
+//
+// The frame is dimmed and converted to grayscale, and each
+// feature's block of pixels is tinted from transparent
+// (zero) through red to yellow (the maximum value).
+// If the frame is nil, a black background is used.
+func Heatmap(g *Grid, values []float64, frame image.Image) *image.RGBA {
+	res := image.NewRGBA(image.Rect(0, 0, g.FrameWidth, g.FrameHeight))
+
+	var maxValue float64
+	for _, v := range values {
+		maxValue = math.Max(maxValue, v)
+	}
+
+	for y := 0; y < g.FrameHeight; y++ {
+		for x := 0; x < g.FrameWidth; x++ {
+			var gray float64
+			if frame != nil {
+				b := frame.Bounds()
+				c := color.GrayModel.Convert(frame.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+				gray = float64(c.Y) / 2
+			}
+			var heat float64
+			feature := (y/g.Stride)*g.Cols() + x/g.Stride
+			if maxValue > 0 && feature < len(values) {
+				heat = values[feature] / maxValue
+			}
+			r := gray + heat*(255-gray)
+			gr := gray + heat*(255*heat-gray)
+			b := gray * (1 - heat)
+			res.SetRGBA(x, y, color.RGBA{
+				R: uint8(r),
+				G: uint8(math.Max(0, gr)),
+				B: uint8(b),
+				A: 0xff,
+			})
+		}
+	}
+	return res
+}
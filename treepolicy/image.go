@@ -0,0 +1,55 @@
+package treepolicy
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+
+	"github.com/unixpickle/essentials"
+)
+
+// LoadFrame reads a PNG frame, such as one saved by the
+// recorder package.
+func LoadFrame(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, essentials.AddCtx("load frame", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, essentials.AddCtx("load frame", err)
+	}
+	return img, nil
+}
+
+// ImageRGB converts the top-left corner of an image to a
+// packed RGB frame, dropping anything outside the frame
+// (such as a recorder's overlay strip).
+func (g *Grid) ImageRGB(img image.Image) ([]uint8, error) {
+	b := img.Bounds()
+	if b.Dx() < g.FrameWidth || b.Dy() < g.FrameHeight {
+		return nil, fmt.Errorf("frame is %dx%d but expected at least %dx%d",
+			b.Dx(), b.Dy(), g.FrameWidth, g.FrameHeight)
+	}
+	res := make([]uint8, 0, g.FrameWidth*g.FrameHeight*3)
+	for y := 0; y < g.FrameHeight; y++ {
+		for x := 0; x < g.FrameWidth; x++ {
+			c := color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+			res = append(res, c.R, c.G, c.B)
+		}
+	}
+	return res, nil
+}
+
+// SavePNG writes an image to a PNG file.
+func SavePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return essentials.AddCtx("save PNG", err)
+	}
+	defer f.Close()
+	return essentials.AddCtx("save PNG", png.Encode(f, img))
+}
@@ -0,0 +1,162 @@
+package treepolicy
+
+import (
+	"math"
+
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+// Importance measures how much a set of trees relies on
+// each feature.
+type Importance struct {
+	// Splits counts the nodes which split on each
+	// feature.
+	Splits []float64
+
+	// Gain is the information gain of the splits on each
+	// feature, weighted by the fraction of inputs which
+	// reach the split and averaged over the trees.
+	Gain []float64
+}
+
+// ComputeImportance computes feature importances for the
+// trees.
+//
+// The trees do not record how their training data was
+// split, so the distribution at each branch is estimated
+// by running the trees on the given feature vectors.
+// If there are no feature vectors, every branch of a split
+// is assumed to be equally likely.
+//
+// Attributes which are not feature indices below
+// numFeatures are ignored.
+func ComputeImportance(trees []*idtrees.Tree, numFeatures int,
+	inputs [][]float64) *Importance {
+	res := &Importance{
+		Splits: make([]float64, numFeatures),
+		Gain:   make([]float64, numFeatures),
+	}
+	for _, tree := range trees {
+		c := &importanceCounter{
+			Importance: res,
+			Visits:     map[*idtrees.Tree]int{},
+			NumTrees:   len(trees),
+		}
+		for _, input := range inputs {
+			c.Visit(tree, input)
+		}
+		c.Accumulate(tree, 1)
+	}
+	return res
+}
+
+type importanceCounter struct {
+	*Importance
+	Visits   map[*idtrees.Tree]int
+	NumTrees int
+}
+
+// Visit records the path of an input through a tree.
+func (i *importanceCounter) Visit(t *idtrees.Tree, input []float64) {
+	for t != nil {
+		i.Visits[t]++
+		t = childFor(t, input)
+	}
+}
+
+// Accumulate adds the importance of the subtree, which is
+// reached with probability mass, and returns the class
+// distribution of the subtree.
+func (i *importanceCounter) Accumulate(t *idtrees.Tree,
+	mass float64) map[idtrees.Class]float64 {
+	children := treeChildren(t)
+	if len(children) == 0 {
+		return t.Classification
+	}
+
+	weights := i.childWeights(t, children)
+	dist := map[idtrees.Class]float64{}
+	var childEntropy float64
+	for j, child := range children {
+		childDist := i.Accumulate(child, mass*weights[j])
+		childEntropy += weights[j] * entropy(childDist)
+		for class, prob := range childDist {
+			dist[class] += weights[j] * prob
+		}
+	}
+
+	if idx, ok := t.Attr.(int); ok && idx >= 0 && idx < len(i.Splits) {
+		i.Splits[idx]++
+		gain := math.Max(0, entropy(dist)-childEntropy)
+		i.Gain[idx] += mass * gain / float64(i.NumTrees)
+	}
+	return dist
+}
+
+func (i *importanceCounter) childWeights(t *idtrees.Tree,
+	children []*idtrees.Tree) []float64 {
+	res := make([]float64, len(children))
+	total := i.Visits[t]
+	for j, child := range children {
+		if total > 0 {
+			res[j] = float64(i.Visits[child]) / float64(total)
+		} else {
+			res[j] = 1 / float64(len(children))
+		}
+	}
+	return res
+}
+
+func treeChildren(t *idtrees.Tree) []*idtrees.Tree {
+	if t.NumSplit != nil {
+		return []*idtrees.Tree{t.NumSplit.LessEqual, t.NumSplit.Greater}
+	}
+	var res []*idtrees.Tree
+	for _, val := range sortedVals(t.ValSplit) {
+		res = append(res, t.ValSplit[val])
+	}
+	return res
+}
+
+// childFor returns the branch taken by an input, or nil if
+// t is a leaf or the input cannot be routed.
+func childFor(t *idtrees.Tree, input []float64) *idtrees.Tree {
+	idx, ok := t.Attr.(int)
+	if !ok || idx < 0 || idx >= len(input) {
+		return nil
+	}
+	if t.NumSplit != nil {
+		thresh, err := numericValue(t.NumSplit.Threshold)
+		if err != nil {
+			return nil
+		}
+		if input[idx] <= thresh {
+			return t.NumSplit.LessEqual
+		}
+		return t.NumSplit.Greater
+	}
+	for val, child := range t.ValSplit {
+		if num, err := numericValue(val); err == nil && num == input[idx] {
+			return child
+		}
+	}
+	return nil
+}
+
+func entropy(dist map[idtrees.Class]float64) float64 {
+	var sum float64
+	for _, prob := range dist {
+		sum += prob
+	}
+	if sum == 0 {
+		return 0
+	}
+	var res float64
+	for _, prob := range dist {
+		if prob > 0 {
+			p := prob / sum
+			res -= p * math.Log2(p)
+		}
+	}
+	return res
+}
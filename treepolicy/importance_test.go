@@ -0,0 +1,52 @@
+package treepolicy
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+func TestComputeImportance(t *testing.T) {
+	leaf := func(class int) *idtrees.Tree {
+		return &idtrees.Tree{Classification: map[idtrees.Class]float64{class: 1}}
+	}
+	tree := &idtrees.Tree{
+		Attr: 1,
+		NumSplit: &idtrees.NumSplit{
+			Threshold: 0.5,
+			LessEqual: leaf(0),
+			Greater: &idtrees.Tree{
+				Attr: 3,
+				NumSplit: &idtrees.NumSplit{
+					Threshold: 0.5,
+					LessEqual: leaf(1),
+					Greater:   leaf(2),
+				},
+			},
+		},
+	}
+
+	uniform := ComputeImportance([]*idtrees.Tree{tree}, 4, nil)
+	expectedSplits := []float64{0, 1, 0, 1}
+	expectedGain := []float64{0, 1.5 - 0.5, 0, 0.5}
+	for i, x := range expectedGain {
+		if uniform.Splits[i] != expectedSplits[i] {
+			t.Errorf("splits[%d]: expected %v but got %v", i, expectedSplits[i],
+				uniform.Splits[i])
+		}
+		if math.Abs(uniform.Gain[i]-x) > 1e-8 {
+			t.Errorf("gain[%d]: expected %v but got %v", i, x, uniform.Gain[i])
+		}
+	}
+
+	// With every input taking the first branch, the second
+	// split is never reached.
+	empirical := ComputeImportance([]*idtrees.Tree{tree}, 4, [][]float64{
+		{0, 0, 0, 0},
+		{0, 0, 1, 1},
+	})
+	if empirical.Gain[1] != 0 || empirical.Gain[3] != 0 {
+		t.Errorf("unexpected gains: %v", empirical.Gain)
+	}
+}
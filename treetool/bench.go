@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/rl-agents/treepolicy"
+	"github.com/unixpickle/weakai/idtrees"
+)
+
+// A classifier is implemented by idtrees trees and
+// forests.
+type classifier interface {
+	Classify(sample idtrees.AttrMap) map[idtrees.Class]float64
+}
+
+// featureMap is a feature vector as an idtrees.AttrMap.
+type featureMap []float64
+
+func (f featureMap) Attr(attr idtrees.Attr) idtrees.Val {
+	return f[attr.(int)]
+}
+
+func BenchMain(args []string) {
+	var policyFile string
+	var numEnvs int
+	var duration time.Duration
+	var gridFlags GridFlags
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	fs.StringVar(&policyFile, "file", "trained_policy", "policy file")
+	fs.IntVar(&numEnvs, "envs", 8, "number of environments per batch")
+	fs.DurationVar(&duration, "duration", 5*time.Second, "time per benchmark")
+	gridFlags.Add(fs)
+	fs.Parse(args)
+
+	policy, meta, err := treepolicy.LoadPolicy(policyFile)
+	if err != nil {
+		essentials.Die(err)
+	}
+	game := gridFlags.Game(meta)
+	compiled, err := treepolicy.Compile(policy)
+	if err != nil {
+		essentials.Die(err)
+	}
+	slow, ok := policy.Classifier.(classifier)
+	if !ok {
+		essentials.Die(fmt.Sprintf("unsupported classifier type: %T", policy.Classifier))
+	}
+
+	// Random frames take every branch of the trees, which
+	// makes for a fair comparison.
+	numFeatures := gridFlags.FeatureExtractor(meta, game).NumFeatures()
+	batch := make([]float64, numEnvs*numFeatures)
+	for i := range batch {
+		batch[i] = float64(rand.Intn(256))
+	}
+	var inputs []featureMap
+	for i := 0; i < numEnvs; i++ {
+		inputs = append(inputs, featureMap(batch[i*numFeatures:(i+1)*numFeatures]))
+	}
+
+	fmt.Printf("trees: %d nodes: %d envs: %d\n", compiled.NumTrees,
+		len(compiled.Nodes), numEnvs)
+
+	idtreesRate := stepsPerSecond(duration, numEnvs, func() {
+		for _, input := range inputs {
+			slow.Classify(input)
+		}
+	})
+	fmt.Printf("idtrees:  %.0f steps/sec\n", idtreesRate)
+
+	out := make([]float64, numEnvs*compiled.NumActions)
+	compiledRate := stepsPerSecond(duration, numEnvs, func() {
+		compiled.ApplyBatch(batch, out)
+	})
+	fmt.Printf("compiled: %.0f steps/sec (%.1fx)\n", compiledRate,
+		compiledRate/idtreesRate)
+}
+
+func stepsPerSecond(duration time.Duration, batchSize int, f func()) float64 {
+	start := time.Now()
+	var steps int
+	for time.Since(start) < duration {
+		f()
+		steps += batchSize
+	}
+	return float64(steps) / time.Since(start).Seconds()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/rl-agents/treepolicy"
+)
+
+func ExportMain(args []string) {
+	var policyFile string
+	var format string
+	var outFile string
+	var pkg string
+	var funcName string
+	var gridFlags GridFlags
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.StringVar(&policyFile, "file", "trained_policy", "policy file")
+	fs.StringVar(&format, "format", "rules", "output format (rules, dot, go)")
+	fs.StringVar(&outFile, "out", "", "output file (default: stdout)")
+	fs.StringVar(&pkg, "pkg", "policy", "package name for Go output")
+	fs.StringVar(&funcName, "func", "Policy", "function name for Go output")
+	gridFlags.Add(fs)
+	fs.Parse(args)
+
+	policy, meta, err := treepolicy.LoadPolicy(policyFile)
+	if err != nil {
+		essentials.Die(err)
+	}
+	trees, err := treepolicy.Trees(policy.Classifier)
+	if err != nil {
+		essentials.Die(err)
+	}
+
+	game := gridFlags.Game(meta)
+	exporter := &treepolicy.Exporter{
+		Grid:        &game.Grid,
+		Features:    gridFlags.FeatureExtractor(meta, game),
+		ActionNames: game.Actions,
+	}
+
+	var w io.Writer = os.Stdout
+	if outFile != "" {
+		f, err := os.Create(outFile)
+		if err != nil {
+			essentials.Die(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case "rules":
+		err = exporter.WriteRules(w, trees)
+	case "dot":
+		err = exporter.WriteDOT(w, trees)
+	case "go":
+		err = exporter.WriteGo(w, trees, pkg, funcName, policy.NumActions,
+			policy.Epsilon)
+	default:
+		essentials.Die("unknown format:", format)
+	}
+	if err != nil {
+		essentials.Die(err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/rl-agents/treepolicy"
+)
+
+// GridFlags selects the frame layout used to interpret a
+// policy's features.
+type GridFlags struct {
+	Name   string
+	Width  int
+	Height int
+	Stride int
+
+	Features string
+}
+
+// Add registers the flags on a flag set.
+func (g *GridFlags) Add(fs *flag.FlagSet) {
+	fs.StringVar(&g.Name, "game", "",
+		"game preset (trex, twins, knightower; default: from the policy file)")
+	fs.IntVar(&g.Width, "width", 0, "frame width (overrides the preset)")
+	fs.IntVar(&g.Height, "height", 0, "frame height (overrides the preset)")
+	fs.IntVar(&g.Stride, "stride", 0, "downsampling stride (overrides the preset)")
+	fs.StringVar(&g.Features, "features", "",
+		"feature extractors (default: from the policy file, or pixels)")
+}
+
+// Game returns the selected game with any overrides
+// applied.
+//
+// If no preset was selected, the policy's metadata is
+// used instead.
+func (g *GridFlags) Game(meta *treepolicy.Metadata) *treepolicy.Game {
+	var res treepolicy.Game
+	if g.Name != "" {
+		preset, ok := treepolicy.Games[g.Name]
+		if !ok {
+			essentials.Die("unknown game:", g.Name)
+		}
+		res = *preset
+	} else if meta != nil {
+		res = *meta.Game()
+	} else {
+		essentials.Die("policy has no metadata: specify a game with -game")
+	}
+	if g.Width != 0 {
+		res.Grid.FrameWidth = g.Width
+	}
+	if g.Height != 0 {
+		res.Grid.FrameHeight = g.Height
+	}
+	if g.Stride != 0 {
+		res.Grid.Stride = g.Stride
+	}
+	return &res
+}
+
+// FeatureSpec returns the selected feature spec.
+func (g *GridFlags) FeatureSpec(meta *treepolicy.Metadata) string {
+	if g.Features != "" {
+		return g.Features
+	} else if meta != nil {
+		return meta.FeatureSpec()
+	}
+	return "pixels"
+}
+
+// FeatureExtractor creates the selected feature extractor
+// for a game.
+func (g *GridFlags) FeatureExtractor(meta *treepolicy.Metadata,
+	game *treepolicy.Game) treepolicy.FeatureExtractor {
+	res, err := treepolicy.ParseFeatures(g.FeatureSpec(meta), &game.Grid)
+	if err != nil {
+		essentials.Die(err)
+	}
+	return res
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/muniverse"
+	"github.com/unixpickle/rl-agents/treepolicy"
+)
+
+// DefaultCaptureSteps is the number of steps to run the
+// game for when no frame is given to the heatmap.
+const DefaultCaptureSteps = 20
+
+func HeatmapMain(args []string) {
+	var policyFile string
+	var metric string
+	var frameFile string
+	var captureSteps int
+	var outFile string
+	var top int
+	var gridFlags GridFlags
+	fs := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	fs.StringVar(&policyFile, "file", "trained_policy", "policy file")
+	fs.StringVar(&metric, "metric", "gain", "importance metric (gain, splits)")
+	fs.StringVar(&frameFile, "frame", "",
+		"PNG frame to draw the heatmap over (default: the last captured frame)")
+	fs.IntVar(&captureSteps, "capture", DefaultCaptureSteps,
+		"number of steps to run the game for sample frames (0 to disable; "+
+			"defaults to 0 with -frame)")
+	fs.StringVar(&outFile, "out", "heatmap.png", "output PNG file")
+	fs.IntVar(&top, "top", 10, "number of top features to print")
+	gridFlags.Add(fs)
+	fs.Parse(args)
+
+	if frameFile != "" && !flagIsSet(fs, "capture") {
+		captureSteps = 0
+	}
+
+	policy, meta, err := treepolicy.LoadPolicy(policyFile)
+	if err != nil {
+		essentials.Die(err)
+	}
+	trees, err := treepolicy.Trees(policy.Classifier)
+	if err != nil {
+		essentials.Die(err)
+	}
+	game := gridFlags.Game(meta)
+	features := gridFlags.FeatureExtractor(meta, game)
+
+	var frame image.Image
+	var inputs [][]float64
+	if frameFile != "" {
+		frame, err = treepolicy.LoadFrame(frameFile)
+		if err != nil {
+			essentials.Die(err)
+		}
+		rgb, err := game.Grid.ImageRGB(frame)
+		if err != nil {
+			essentials.Die(err)
+		}
+		features.Reset()
+		inputs = append(inputs, features.Extract(rgb))
+	}
+	if captureSteps > 0 {
+		log.Printf("Capturing %d frames from %s...", captureSteps, game.Spec)
+		frames, err := captureFrames(game, captureSteps)
+		if err != nil {
+			essentials.Die(err)
+		}
+		features.Reset()
+		for _, f := range frames {
+			inputs = append(inputs, features.Extract(f))
+		}
+		if frame == nil {
+			frame = rgbImage(frames[len(frames)-1], &game.Grid)
+		}
+	}
+
+	importance := treepolicy.ComputeImportance(trees, features.NumFeatures(), inputs)
+	var values []float64
+	switch metric {
+	case "gain":
+		values = importance.Gain
+	case "splits":
+		values = importance.Splits
+	default:
+		essentials.Die("unknown metric:", metric)
+	}
+
+	printTopFeatures(features, values, top)
+
+	cellValues := treepolicy.GridValues(features, &game.Grid, values)
+	heatmap := treepolicy.Heatmap(&game.Grid, cellValues, frame)
+	if err := treepolicy.SavePNG(outFile, heatmap); err != nil {
+		essentials.Die(err)
+	}
+}
+
+func flagIsSet(fs *flag.FlagSet, name string) bool {
+	var res bool
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			res = true
+		}
+	})
+	return res
+}
+
+func printTopFeatures(f treepolicy.FeatureExtractor, values []float64, top int) {
+	indices := make([]int, len(values))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return values[indices[i]] > values[indices[j]]
+	})
+	for i, idx := range indices {
+		if i == top || values[idx] == 0 {
+			break
+		}
+		fmt.Printf("%s\t%.4g\n", f.FeatureName(idx), values[idx])
+	}
+}
+
+// captureFrames plays the game without pressing any keys
+// and returns the RGB frames it sees.
+func captureFrames(game *treepolicy.Game, steps int) ([][]uint8, error) {
+	spec := muniverse.SpecForName(game.Spec)
+	if spec == nil {
+		return nil, fmt.Errorf("capture frames: environment not found: %s", game.Spec)
+	}
+	env, err := muniverse.NewEnv(spec)
+	if err != nil {
+		return nil, essentials.AddCtx("capture frames", err)
+	}
+	defer env.Close()
+
+	if err := env.Reset(); err != nil {
+		return nil, essentials.AddCtx("capture frames", err)
+	}
+	var res [][]uint8
+	for i := 0; i < steps; i++ {
+		obs, err := env.Observe()
+		if err != nil {
+			return nil, essentials.AddCtx("capture frames", err)
+		}
+		buffer, _, _, err := muniverse.RGB(obs)
+		if err != nil {
+			return nil, essentials.AddCtx("capture frames", err)
+		}
+		res = append(res, buffer)
+		_, done, err := env.Step(time.Second / 10)
+		if err != nil {
+			return nil, essentials.AddCtx("capture frames", err)
+		}
+		if done {
+			if err := env.Reset(); err != nil {
+				return nil, essentials.AddCtx("capture frames", err)
+			}
+		}
+	}
+	return res, nil
+}
+
+func rgbImage(rgb []uint8, g *treepolicy.Grid) image.Image {
+	res := image.NewRGBA(image.Rect(0, 0, g.FrameWidth, g.FrameHeight))
+	for i := 0; i < g.FrameWidth*g.FrameHeight; i++ {
+		res.Pix[i*4] = rgb[i*3]
+		res.Pix[i*4+1] = rgb[i*3+1]
+		res.Pix[i*4+2] = rgb[i*3+2]
+		res.Pix[i*4+3] = 0xff
+	}
+	return res
+}
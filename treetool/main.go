@@ -0,0 +1,46 @@
+// Command treetool inspects tree policies saved by the
+// tree agents.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		dieUsage()
+	}
+	switch os.Args[1] {
+	case "bench":
+		BenchMain(os.Args[2:])
+	case "export":
+		ExportMain(os.Args[2:])
+	case "heatmap":
+		HeatmapMain(os.Args[2:])
+	case "info":
+		InfoMain(os.Args[2:])
+	case "migrate":
+		MigrateMain(os.Args[2:])
+	default:
+		dieUsage()
+	}
+}
+
+func dieUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: treetool <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  bench     compare compiled and idtrees evaluation speed")
+	fmt.Fprintln(os.Stderr, "  export    render a policy as rules, DOT, or Go source")
+	fmt.Fprintln(os.Stderr, "  heatmap   draw the pixels a policy relies on")
+	fmt.Fprintln(os.Stderr, "  info      print a policy's metadata")
+	fmt.Fprintln(os.Stderr, "  migrate   convert a legacy policy to the current format")
+	os.Exit(1)
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/rl-agents/treepolicy"
+)
+
+func MigrateMain(args []string) {
+	var policyFile string
+	var outFile string
+	var gridFlags GridFlags
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.StringVar(&policyFile, "file", "trained_policy", "policy file")
+	fs.StringVar(&outFile, "out", "", "output file (default: overwrite the input)")
+	gridFlags.Add(fs)
+	fs.Parse(args)
+
+	if outFile == "" {
+		outFile = policyFile
+	}
+
+	policy, meta, err := treepolicy.LoadPolicy(policyFile)
+	if err != nil {
+		essentials.Die(err)
+	}
+	features := gridFlags.FeatureSpec(meta)
+	if meta == nil {
+		// Legacy policies predate feature extractors.
+		if features != "pixels" {
+			essentials.Die("legacy policy was trained with features: pixels")
+		}
+		log.Println("Migrating legacy policy file...")
+	}
+	meta = treepolicy.GameMetadata(gridFlags.Game(meta))
+	meta.Features = features
+	if err := treepolicy.SavePolicy(outFile, policy, meta); err != nil {
+		essentials.Die(err)
+	}
+	log.Println("Saved policy to", outFile)
+}
+
+func InfoMain(args []string) {
+	var policyFile string
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	fs.StringVar(&policyFile, "file", "trained_policy", "policy file")
+	fs.Parse(args)
+
+	policy, meta, err := treepolicy.LoadPolicy(policyFile)
+	if err != nil {
+		essentials.Die(err)
+	}
+	trees, err := treepolicy.Trees(policy.Classifier)
+	if err != nil {
+		essentials.Die(err)
+	}
+
+	if meta == nil {
+		fmt.Println("format: legacy gob (run migrate to upgrade)")
+	} else {
+		fmt.Println("format: self-describing")
+		fmt.Println("game:", meta.Spec)
+		fmt.Printf("frame: %dx%d (stride %d)\n", meta.FrameWidth, meta.FrameHeight,
+			meta.Stride)
+		fmt.Println("actions:", strings.Join(meta.Actions, ", "))
+		if meta.Features != "" {
+			fmt.Println("features:", meta.Features)
+		}
+	}
+	fmt.Println("num actions:", policy.NumActions)
+	fmt.Println("epsilon:", policy.Epsilon)
+	fmt.Println("trees:", len(trees))
+}
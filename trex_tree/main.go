@@ -1,12 +1,11 @@
 package main
 
 import (
-	"bytes"
 	"compress/flate"
-	"encoding/gob"
-	"io/ioutil"
+	"flag"
 	"log"
 	"math"
+	"os"
 	"sync"
 	"time"
 
@@ -15,9 +14,11 @@ import (
 	"github.com/unixpickle/anyrl/anypg"
 	"github.com/unixpickle/anyvec"
 	"github.com/unixpickle/anyvec/anyvec32"
+	"github.com/unixpickle/essentials"
 	"github.com/unixpickle/lazyseq"
 	"github.com/unixpickle/muniverse"
 	"github.com/unixpickle/rip"
+	"github.com/unixpickle/rl-agents/treepolicy"
 	"github.com/unixpickle/treeagent"
 	"github.com/unixpickle/weakai/idtrees"
 )
@@ -34,9 +35,14 @@ const (
 	SaveFile = "trained_policy"
 )
 
+// Metadata is saved alongside the policy.
+var Metadata = treepolicy.GameMetadata(treepolicy.Games["trex"])
+
 func main() {
-	gob.Register(&idtrees.Tree{})
-	gob.Register(idtrees.Forest{})
+	flag.StringVar(&Metadata.Features, "features", "pixels",
+		"feature extractors (comma separated: pixels, diff, edges, pool)")
+	flag.Parse()
+	numFeatures := newFeatureExtractor().NumFeatures()
 
 	// Setup vector creator.
 	creator := anyvec32.CurrentCreator()
@@ -60,9 +66,9 @@ func main() {
 	// Setup a trainer for producing new policies.
 	trainer := &treeagent.Trainer{
 		NumTrees:    20,
-		NumFeatures: PreprocessedSize,
+		NumFeatures: numFeatures,
 		Judger:      &anypg.QJudger{Discount: 0.98},
-		UseFeatures: PreprocessedSize / 10,
+		UseFeatures: numFeatures / 10,
 		BuildTree: func(samples []idtrees.Sample, attrs []idtrees.Attr) *idtrees.Tree {
 			return idtrees.LimitedID3(samples, attrs, 0, 4)
 		},
@@ -89,10 +95,7 @@ func main() {
 
 			// Save the new policy.
 			trainLock.Lock()
-			var data bytes.Buffer
-			enc := gob.NewEncoder(&data)
-			must(enc.Encode(policy))
-			must(ioutil.WriteFile(SaveFile, data.Bytes(), 0755))
+			must(treepolicy.SavePolicy(SaveFile, policy, Metadata))
 			trainLock.Unlock()
 		}
 	}()
@@ -119,7 +122,7 @@ func gatherRollouts(roller *treeagent.Roller) []*anyrl.RolloutSet {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			spec := muniverse.SpecForName("TRex-v0")
+			spec := muniverse.SpecForName(Metadata.Spec)
 			if spec == nil {
 				panic("environment not found")
 			}
@@ -132,8 +135,9 @@ func gatherRollouts(roller *treeagent.Roller) []*anyrl.RolloutSet {
 			defer env.Close()
 
 			preproc := &PreprocessEnv{
-				Env:     env,
-				Creator: roller.Creator,
+				Env:      env,
+				Creator:  roller.Creator,
+				Features: newFeatureExtractor(),
 			}
 			for _ = range requests {
 				rollout, err := roller.Rollout(preproc)
@@ -165,8 +169,7 @@ func gatherRollouts(roller *treeagent.Roller) []*anyrl.RolloutSet {
 }
 
 func loadOrCreatePolicy(creator anyvec.Creator) *treeagent.Policy {
-	data, err := ioutil.ReadFile(SaveFile)
-	if err != nil {
+	if _, err := os.Stat(SaveFile); os.IsNotExist(err) {
 		log.Println("Created new policy.")
 		return &treeagent.Policy{
 			Classifier: &idtrees.Tree{
@@ -181,10 +184,26 @@ func loadOrCreatePolicy(creator anyvec.Creator) *treeagent.Policy {
 			Epsilon:    0.05,
 		}
 	}
-	var res *treeagent.Policy
-	dec := gob.NewDecoder(bytes.NewReader(data))
-	must(dec.Decode(&res))
-	log.Println("Loaded policy from file.")
+	res, meta, err := treepolicy.LoadPolicy(SaveFile)
+	must(err)
+	if meta == nil {
+		// Legacy policies predate feature extractors.
+		if Metadata.FeatureSpec() != "pixels" {
+			essentials.Die("legacy policy was trained with features: pixels")
+		}
+		log.Println("Loaded legacy policy from file.")
+	} else {
+		if meta.FeatureSpec() != Metadata.FeatureSpec() {
+			essentials.Die("policy was trained with features: " + meta.FeatureSpec())
+		}
+		log.Println("Loaded policy from file.")
+	}
+	return res
+}
+
+func newFeatureExtractor() treepolicy.FeatureExtractor {
+	res, err := Metadata.FeatureExtractor()
+	must(err)
 	return res
 }
 
@@ -2,23 +2,19 @@ package main
 
 import (
 	"github.com/unixpickle/anyvec"
-	"github.com/unixpickle/essentials"
 	"github.com/unixpickle/muniverse"
 	"github.com/unixpickle/muniverse/chrome"
+	"github.com/unixpickle/rl-agents/treepolicy"
 )
 
 const (
-	FrameWidth  = 320
-	FrameHeight = 480
-
 	MaxTimestep = 60 * 10
-
-	PreprocessedSize = (FrameWidth / 4) * (FrameHeight / 4)
 )
 
 type PreprocessEnv struct {
-	Env     muniverse.Env
-	Creator anyvec.Creator
+	Env      muniverse.Env
+	Creator  anyvec.Creator
+	Features treepolicy.FeatureExtractor
 
 	Timestep int
 
@@ -38,7 +34,8 @@ func (p *PreprocessEnv) Reset() (observation anyvec.Vector, err error) {
 	if err != nil {
 		return
 	}
-	observation = p.simplifyImage(buffer)
+	p.Features.Reset()
+	observation = p.extractFeatures(buffer)
 	p.Timestep = 0
 	return
 }
@@ -73,7 +70,7 @@ func (p *PreprocessEnv) Step(action anyvec.Vector) (observation anyvec.Vector,
 	if err != nil {
 		return
 	}
-	observation = p.simplifyImage(buffer)
+	observation = p.extractFeatures(buffer)
 
 	p.Timestep++
 	if p.Timestep > MaxTimestep {
@@ -82,17 +79,7 @@ func (p *PreprocessEnv) Step(action anyvec.Vector) (observation anyvec.Vector,
 	return
 }
 
-func (p *PreprocessEnv) simplifyImage(in []uint8) anyvec.Vector {
-	data := make([]float64, 0, PreprocessedSize)
-	for y := 0; y < FrameHeight; y += 4 {
-		for x := 0; x < FrameWidth; x += 4 {
-			sourceIdx := (y*FrameWidth + x) * 3
-			var value float64
-			for d := 0; d < 3; d++ {
-				value += float64(in[sourceIdx+d])
-			}
-			data = append(data, essentials.Round(value/3))
-		}
-	}
+func (p *PreprocessEnv) extractFeatures(in []uint8) anyvec.Vector {
+	data := p.Features.Extract(in)
 	return p.Creator.MakeVectorData(p.Creator.MakeNumericList(data))
 }